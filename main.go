@@ -17,33 +17,145 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
 
 	"github.com/hacbs-release/release-availability-metrics/pkg/checks"
 	"github.com/hacbs-release/release-availability-metrics/pkg/config"
+	"github.com/hacbs-release/release-availability-metrics/pkg/maintenance"
 	"github.com/hacbs-release/release-availability-metrics/pkg/metrics"
-	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/hacbs-release/release-availability-metrics/pkg/report"
+	"github.com/hacbs-release/release-availability-metrics/pkg/telemetry"
 )
 
 var (
 	pollInterval int
 
-	git   []*checks.GitCheck
-	quay  []*checks.QuayCheck
-	_http []*checks.HttpCheck
+	git       []*checks.GitCheck
+	quay      []*checks.QuayCheck
+	_http     []*checks.HttpCheck
+	composite []*checks.CompositeCheck
 
 	logger = log.New(os.Stdout, "metrics-server: ", log.LstdFlags)
 )
 
-func collectAndRecord(ctx context.Context, cfg *config.Config) {
+// sloSaveInterval is the interval the SLO run history is persisted at
+const sloSaveInterval = 5 * time.Minute
+
+// sloWindow returns the window of an objective, defaulting to 30 days.
+func sloWindow(window config.Duration) time.Duration {
+	if window == 0 {
+		return 30 * 24 * time.Hour
+	}
+
+	return time.Duration(window)
+}
+
+// addObjective sets the availability objective of a check, if it has one.
+func addObjective(slo *metrics.SLOMetric, name string, sloConfig *config.SLOConfig) {
+	if sloConfig == nil {
+		return
+	}
+
+	if err := slo.AddObjective(name, sloConfig.Target/100, sloWindow(sloConfig.Window)); err != nil {
+		logger.Printf("[ERROR] %s: %v\n", name, err)
+	}
+}
+
+// stringList is a flag which can be repeated or set to a comma separated list.
+type stringList []string
+
+// String returns the values of the flag joined by commas.
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+// Set adds the comma separated values to the flag.
+func (l *stringList) Set(value string) error {
+	*l = append(*l, strings.Split(value, ",")...)
+	return nil
+}
+
+// usageError prints the error of a command line argument along with the usage and exits.
+func usageError(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	flag.Usage()
+	os.Exit(2)
+}
+
+// monitor holds the scheduled checks and the components exporting their results.
+type monitor struct {
+	registry    *metrics.Registry
+	maintenance *maintenance.Manager
+	scheduler   *checks.Scheduler
+	exporter    *telemetry.Exporter
+	pusher      *metrics.Pusher
+	slo         *metrics.SLOMetric
+}
+
+// round runs all the checks once and pushes their metrics, if the push mode is enabled. The results of the
+// checks are returned along with the push error.
+func (m *monitor) round(ctx context.Context) ([]checks.Result, error) {
+	results := m.scheduler.Run(ctx)
+	if m.pusher == nil {
+		return results, nil
+	}
+
+	if err := m.pusher.Push(ctx); err != nil {
+		logger.Printf("[ERROR] push failed: %v\n", err)
+		return results, err
+	}
+
+	return results, nil
+}
+
+// run runs the checks every poll interval until the context is done.
+func (m *monitor) run(ctx context.Context) {
+	ticker := time.NewTicker(time.Duration(pollInterval) * time.Second)
+	defer ticker.Stop()
+	saveTicker := time.NewTicker(sloSaveInterval)
+	defer saveTicker.Stop()
+
+	// Run checks immediately on start
+	m.round(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Println("shutting down check loop")
+			return
+		case <-ticker.C:
+			m.round(ctx)
+		case <-saveTicker.C:
+			m.slo.Save()
+		}
+	}
+}
+
+// shutdown saves the SLO run history and flushes the OTLP exporter, if enabled.
+func (m *monitor) shutdown(ctx context.Context) {
+	m.slo.Save()
+	if m.exporter == nil {
+		return
+	}
+
+	if err := m.exporter.Shutdown(ctx); err != nil {
+		logger.Printf("OTLP exporter shutdown error: %v\n", err)
+	}
+}
+
+// collectAndRecord instances the checks and returns the monitor scheduling them, along with the registry
+// holding their metrics and the exporters pushing them. Only the selected checks, and the checks they
+// depend on, are scheduled if any is selected.
+func collectAndRecord(ctx context.Context, cfg *config.Config, selected []string) *monitor {
 	// default internal
 	pollInterval = cfg.Service.PollInterval
 	if pollInterval == 0 {
@@ -57,15 +169,126 @@ func collectAndRecord(ctx context.Context, cfg *config.Config) {
 		prefix = "metrics_server"
 	}
 
-	gaugeMetric := metrics.NewGaugeMetric(prefix, []string{"check"})
-	histogramMetric := metrics.NewHistogramMetric(prefix, []string{"check", "reason", "status"})
+	// all the series share the static labels of every check
+	checkLabels := []map[string]string{}
+	for _, check := range cfg.Checks.Git {
+		checkLabels = append(checkLabels, check.Labels)
+	}
+	for _, check := range cfg.Checks.Quay {
+		checkLabels = append(checkLabels, check.Labels)
+	}
+	for _, check := range cfg.Checks.Http {
+		checkLabels = append(checkLabels, check.Labels)
+	}
+	for _, check := range cfg.Checks.Composite {
+		checkLabels = append(checkLabels, check.Labels)
+	}
+	staticLabels, err := metrics.StaticLabelNames(checkLabels)
+	if err != nil {
+		panic(err)
+	}
+
+	metric := metrics.NewCompositeMetric(prefix, staticLabels)
+	gitMetric := metrics.NewGitMetric(prefix, staticLabels)
+	scoreMetric := metrics.NewNamedGaugeMetric(prefix, "composite_score", metric.LabelNames())
+	metric.SLO = metrics.NewSLOMetric(prefix, staticLabels, cfg.Service.StateDir, logger)
+
+	windows := []maintenance.Window{}
+	for _, window := range cfg.Maintenance {
+		windows = append(windows, maintenance.Window{
+			Name:     window.Name,
+			Checks:   window.Checks,
+			Selector: window.Selector,
+			Cron:     window.Cron,
+			Duration: time.Duration(window.Duration),
+			Timezone: window.Timezone,
+			Start:    window.Start,
+			End:      window.End,
+		})
+	}
+	maintenanceManager, err := maintenance.NewManager(windows, cfg.Service.StateDir, logger)
+	if err != nil {
+		panic(err)
+	}
+	metric.Maintenance = maintenanceManager
+
+	registry := metrics.NewRegistry(prefix, cfg.Service.RuntimeMetrics)
+	collectors := append(metric.Collectors(), gitMetric.Collectors()...)
+	collectors = append(collectors, scoreMetric.Metric)
+	if err := registry.Register(append(collectors, metric.SLO.Collectors()...)...); err != nil {
+		panic(err)
+	}
+
+	// the exporter is set up before the first run, so that it is traced
+	var exporter *telemetry.Exporter
+	if otlp := cfg.Service.OTLP; otlp != nil {
+		exporter, err = telemetry.NewExporter(ctx, telemetry.Options{
+			Endpoint:    otlp.Endpoint,
+			Protocol:    otlp.Protocol,
+			Insecure:    otlp.Insecure,
+			Headers:     otlp.Headers,
+			Interval:    time.Duration(otlp.Interval),
+			ServiceName: prefix,
+		}, registry.Gatherer())
+		if err != nil {
+			panic(err)
+		}
+		logger.Printf("exporting metrics and traces to %s\n", otlp.Endpoint)
+	}
 
-	prometheus.MustRegister(gaugeMetric.Metric)
-	prometheus.MustRegister(histogramMetric.Metric)
+	var pusher *metrics.Pusher
+	if push := cfg.Service.Push; push != nil {
+		// get the credentials from env if not specified in config
+		password := os.Getenv("SERVICE_PUSH_PASSWORD")
+		if password == "" {
+			password = push.Password
+		}
+		bearerToken := os.Getenv("SERVICE_PUSH_BEARER_TOKEN")
+		if bearerToken == "" {
+			bearerToken = push.BearerToken
+		}
+		job := push.Job
+		if job == "" {
+			job = prefix
+		}
+		pusher, err = metrics.NewPusher(push.Url, metrics.PushOptions{
+			Mode:        push.Mode,
+			Job:         job,
+			Instance:    push.Instance,
+			Username:    push.Username,
+			Password:    password,
+			BearerToken: bearerToken,
+			Timeout:     time.Duration(push.Timeout),
+		}, registry)
+		if err != nil {
+			panic(err)
+		}
+		logger.Printf("pushing metrics to %s\n", push.Url)
+	}
 
-	metric := metrics.CompositeMetric{
-		Gauge:     gaugeMetric,
-		Histogram: histogramMetric,
+	// objectives and dependencies of all the checks
+	dependsOn := map[string][]string{}
+	for _, check := range cfg.Checks.Git {
+		addObjective(metric.SLO, check.Name, check.SLO)
+		dependsOn[check.Name] = check.DependsOn
+	}
+	for _, check := range cfg.Checks.Quay {
+		addObjective(metric.SLO, check.Name, check.SLO)
+		dependsOn[check.Name] = check.DependsOn
+	}
+	for _, check := range cfg.Checks.Http {
+		addObjective(metric.SLO, check.Name, check.SLO)
+		dependsOn[check.Name] = check.DependsOn
+	}
+	for _, check := range cfg.Checks.Composite {
+		addObjective(metric.SLO, check.Name, check.SLO)
+		dependsOn[check.Name] = check.DependsOn
+	}
+	for _, slo := range cfg.SLOs {
+		if err := metric.SLO.AddGroupObjective(slo.Name, slo.Checks, slo.Selector, slo.Target/100,
+			sloWindow(slo.Window)); err != nil {
+			logger.Printf("[ERROR] %s: %v\n", slo.Name, err)
+		}
 	}
 
 	// instance git checks, if defined
@@ -77,6 +300,26 @@ func collectAndRecord(ctx context.Context, cfg *config.Config) {
 			if token == "" {
 				token = gitCheck.Token
 			}
+			sshKey := os.Getenv(fmt.Sprintf("%s_GIT_SSH_KEY", strings.ToUpper(gitCheck.Name)))
+			sshPassphrase := os.Getenv(fmt.Sprintf("%s_GIT_SSH_PASSPHRASE", strings.ToUpper(gitCheck.Name)))
+			if sshKey == "" {
+				sshKey = gitCheck.SSHKey
+			}
+			if sshPassphrase == "" {
+				sshPassphrase = gitCheck.SSHPassphrase
+			}
+			var githubApp *checks.GithubApp
+			if app := gitCheck.Auth.GithubApp; app != nil {
+				privateKey := os.Getenv(fmt.Sprintf("%s_GIT_GITHUB_APP_PRIVATE_KEY", strings.ToUpper(gitCheck.Name)))
+				if privateKey == "" {
+					privateKey = app.PrivateKey
+				}
+				githubApp = &checks.GithubApp{
+					AppId:          app.AppId,
+					InstallationId: app.InstallationId,
+					PrivateKey:     privateKey,
+				}
+			}
 			newCheck := checks.NewGitCheck(
 				cfg.Service.MetricsPrefix,
 				gitCheck.Name,
@@ -84,8 +327,29 @@ func collectAndRecord(ctx context.Context, cfg *config.Config) {
 				gitCheck.Url,
 				gitCheck.Revision,
 				gitCheck.Path,
+				checks.GitOptions{
+					Mode:           gitCheck.Mode,
+					SSHKey:         sshKey,
+					SSHPassphrase:  sshPassphrase,
+					KnownHosts:     gitCheck.KnownHosts,
+					KnownHostsFile: gitCheck.KnownHostsFile,
+					RefType:        gitCheck.RefType,
+					ContentRegex:   gitCheck.ContentRegex,
+					Sha256:         gitCheck.Sha256,
+					YamlPath:       gitCheck.YamlPath,
+					JsonPath:       gitCheck.JsonPath,
+					MaxCommitAge:   time.Duration(gitCheck.MaxCommitAge),
+					Provider:       gitCheck.Provider,
+					ApiUrl:         gitCheck.ApiUrl,
+					CacheDir:       gitCheck.CacheDir,
+					Username:       gitCheck.Auth.Username,
+					GithubApp:      githubApp,
+					TrustedKeys:    gitCheck.TrustedKeys,
+				},
+				gitCheck.Labels,
 				logger,
-				metric)
+				metric,
+				gitMetric)
 			git = append(git, newCheck)
 		}
 	}
@@ -94,20 +358,27 @@ func collectAndRecord(ctx context.Context, cfg *config.Config) {
 	if len(cfg.Checks.Quay) != 0 {
 		for i := 0; i < len(cfg.Checks.Quay); i++ {
 			quayCheck := cfg.Checks.Quay[i]
-			username := os.Getenv(fmt.Sprintf("%s_QUAY_USERNAME", strings.ToUpper(quayCheck.Name)))
-			password := os.Getenv(fmt.Sprintf("%s_QUAY_PASSWORD", strings.ToUpper(quayCheck.Name)))
-			if username == "" {
-				username = quayCheck.Username
-			}
-			if password == "" {
-				password = quayCheck.Password
+			// fall back to the auth file used by the container tools
+			authFile := quayCheck.AuthFile
+			if authFile == "" {
+				authFile = os.Getenv("REGISTRY_AUTH_FILE")
 			}
-			auth := checks.NewQuayAuth(username, password)
+			auth := checks.NewQuayAuth(authFile)
 			newCheck := checks.NewQuayCheck(
 				auth,
 				quayCheck.Name,
 				quayCheck.PullSpec,
 				quayCheck.Tags,
+				checks.QuayOptions{
+					PullLayers:   quayCheck.PullLayers,
+					Insecure:     quayCheck.Insecure,
+					PlainHttp:    quayCheck.PlainHttp,
+					CaBundle:     quayCheck.CaBundle,
+					Signatures:   quayCheck.Signatures,
+					Attestations: quayCheck.Attestations,
+					Referrers:    quayCheck.Referrers,
+				},
+				quayCheck.Labels,
 				logger,
 				metric)
 			quay = append(quay, newCheck)
@@ -143,50 +414,99 @@ func collectAndRecord(ctx context.Context, cfg *config.Config) {
 				key,
 				httpCheck.Insecure,
 				httpCheck.Follow,
+				httpCheck.Labels,
 				logger,
 				metric)
 			_http = append(_http, newCheck)
 		}
 	}
 
-	go func() {
-		ticker := time.NewTicker(time.Duration(pollInterval) * time.Second)
-		defer ticker.Stop()
+	// instance composite checks, if defined
+	results := checks.NewResults()
+	for _, compositeCheck := range cfg.Checks.Composite {
+		newCheck := checks.NewCompositeCheck(
+			compositeCheck.Name,
+			compositeCheck.Checks,
+			checks.CompositeOptions{
+				Mode:      compositeCheck.Mode,
+				Min:       compositeCheck.Min,
+				Weights:   compositeCheck.Weights,
+				Threshold: compositeCheck.Threshold,
+			},
+			results,
+			compositeCheck.Labels,
+			logger,
+			metric,
+			scoreMetric)
+		composite = append(composite, newCheck)
+	}
 
-		runChecks := func() {
-			for _, check := range git {
-				check.Check(ctx)
-			}
-			for _, check := range _http {
-				check.Check(ctx)
-			}
-			for _, check := range quay {
-				check.Check(ctx)
-			}
+	// composite checks run after the checks they aggregate
+	all := []checks.Check{}
+	for _, check := range git {
+		all = append(all, check)
+	}
+	for _, check := range _http {
+		all = append(all, check)
+	}
+	for _, check := range quay {
+		all = append(all, check)
+	}
+	for _, check := range composite {
+		all = append(all, check)
+	}
+	if len(selected) != 0 {
+		if all, err = checks.Select(all, dependsOn, selected); err != nil {
+			panic(err)
 		}
+	}
+	scheduler, err := checks.NewScheduler(all, dependsOn, results)
+	if err != nil {
+		panic(err)
+	}
 
-		// Run checks immediately on start
-		runChecks()
-
-		for {
-			select {
-			case <-ctx.Done():
-				logger.Println("shutting down check loop")
-				return
-			case <-ticker.C:
-				runChecks()
-			}
-		}
-	}()
+	return &monitor{
+		registry:    registry,
+		maintenance: maintenanceManager,
+		scheduler:   scheduler,
+		exporter:    exporter,
+		pusher:      pusher,
+		slo:         metric.SLO,
+	}
 }
 
 func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
 	defer stop()
 
-	cfgFilePath := "server-config.yaml"
-	if len(os.Args) > 1 {
-		cfgFilePath = os.Args[1]
+	var selected stringList
+	configFile := flag.String("config", "", "path of the config file (default \"server-config.yaml\")")
+	once := flag.Bool("once", false,
+		"run the checks once, print their results, push their metrics and exit, with a non-zero status if any failed")
+	output := flag.String("output", report.FORMAT_TABLE, "format of the --once results: table, json or junit")
+	flag.Var(&selected, "check", "name of a check to run along with its dependencies, can be repeated (default all)")
+	flag.Parse()
+
+	if err := report.ValidFormat(*output); err != nil {
+		usageError(err)
+	}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "output" && !*once {
+			usageError(fmt.Errorf("--output requires --once"))
+		}
+	})
+	// the report is printed on stdout
+	if *once {
+		logger.SetOutput(os.Stderr)
+	}
+
+	// the config file used to be the only argument
+	cfgFilePath := *configFile
+	if cfgFilePath == "" {
+		cfgFilePath = "server-config.yaml"
+		if flag.NArg() > 0 {
+			cfgFilePath = flag.Arg(0)
+		}
 	}
 
 	logger.Printf("loading config from: %s\n", cfgFilePath)
@@ -194,9 +514,43 @@ func main() {
 	if err != nil {
 		panic(err)
 	}
+	for _, name := range selected {
+		if !slices.Contains(cfg.Checks.Names(), name) {
+			usageError(fmt.Errorf("unknown check %s", name))
+		}
+	}
 
-	collectAndRecord(ctx, &cfg)
-	http.Handle("/metrics", promhttp.Handler())
+	m := collectAndRecord(ctx, &cfg, selected)
+	if *once {
+		results, err := m.round(ctx)
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		m.shutdown(shutdownCtx)
+		cancel()
+		if writeErr := report.Write(os.Stdout, *output, results); writeErr != nil {
+			logger.Printf("[ERROR] %v\n", writeErr)
+			err = writeErr
+		}
+		if err != nil || report.Failed(results) {
+			os.Exit(1)
+		}
+		return
+	}
+	go m.run(ctx)
+
+	apiToken := os.Getenv("SERVICE_API_TOKEN")
+	if apiToken == "" {
+		apiToken = cfg.Service.ApiToken
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.registry.Handler())
+	// anyone reaching the metrics port could silence every check otherwise
+	if apiToken != "" || cfg.Service.AllowUnauthenticatedApi {
+		silences := m.maintenance.Handler(apiToken)
+		mux.Handle("/api/v1/silences", silences)
+		mux.Handle("/api/v1/silences/", silences)
+	} else {
+		logger.Println("silences API disabled, no api_token set")
+	}
 
 	listenPort := cfg.Service.ListenPort
 	if listenPort == 0 {
@@ -204,7 +558,8 @@ func main() {
 	}
 
 	server := &http.Server{
-		Addr: fmt.Sprintf(":%d", listenPort),
+		Addr:    fmt.Sprintf(":%d", listenPort),
+		Handler: mux,
 	}
 
 	go func() {
@@ -223,5 +578,6 @@ func main() {
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.Printf("server shutdown error: %v\n", err)
 	}
+	m.shutdown(shutdownCtx)
 	logger.Println("server stopped")
 }
@@ -15,27 +15,107 @@ limitations under the License.
 */
 package checks
 
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// QuayAuth resolves registry credentials from a docker config.json file, like the .dockerconfigjson key
+// of a kubernetes.io/dockerconfigjson pull secret.
 type QuayAuth struct {
-	username string
-	password string
+	authFile string
+}
+
+// dockerConfig is the format of a docker config.json file.
+type dockerConfig struct {
+	Auths map[string]dockerAuthEntry `json:"auths"`
 }
 
-// NewQuayAuth returns a new instance of QuayAuth
-func NewQuayAuth(username string, password string) *QuayAuth {
+// dockerAuthEntry holds the credentials of a registry in a docker config.json file.
+type dockerAuthEntry struct {
+	Auth     string `json:"auth"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// NewQuayAuth returns a new instance of QuayAuth. The file is read on every lookup, so rotated secrets
+// are picked up without a restart. No credentials are used when authFile is empty.
+func NewQuayAuth(authFile string) *QuayAuth {
 	auth := &QuayAuth{
-		username: username,
-		password: password,
+		authFile: authFile,
 	}
 
 	return auth
 }
 
-// getUsername return a QuayAuth username
-func (a *QuayAuth) getUsername() string {
-	return a.username
+// getCredentials returns the username and password for a repository in a registry. Like container tools
+// do, the most specific entry wins: registry/namespace/repo, then registry/namespace, then registry.
+// Empty credentials are returned if no entry matches.
+func (a *QuayAuth) getCredentials(registry, repository string) (string, string, error) {
+	if a.authFile == "" {
+		return "", "", nil
+	}
+
+	data, err := os.ReadFile(a.authFile)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read auth file: %v", err)
+	}
+	cfg := dockerConfig{}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return "", "", fmt.Errorf("failed to parse auth file %s: %v", a.authFile, err)
+	}
+
+	entries := map[string]dockerAuthEntry{}
+	for key, entry := range cfg.Auths {
+		entries[normalizeAuthKey(key)] = entry
+	}
+
+	scope := registry + "/" + repository
+	for {
+		if entry, ok := entries[scope]; ok {
+			return entry.decode()
+		}
+		i := strings.LastIndex(scope, "/")
+		if i == -1 {
+			break
+		}
+		scope = scope[:i]
+	}
+
+	return "", "", nil
+}
+
+// normalizeAuthKey strips the scheme and the legacy api paths of an auth file key, and maps the docker
+// hub aliases to docker.io.
+func normalizeAuthKey(key string) string {
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	key = strings.TrimSuffix(key, "/")
+	key = strings.TrimSuffix(strings.TrimSuffix(key, "/v1"), "/v2")
+	switch key {
+	case "index.docker.io", DEFAULT_DOMAIN_HOST:
+		return DEFAULT_DOMAIN
+	}
+
+	return key
 }
 
-// getPassword return a QuayAuth password
-func (a *QuayAuth) getPassword() string {
-	return a.password
+// decode returns the username and password of an auth entry, preferring the base64 encoded auth field.
+func (e dockerAuthEntry) decode() (string, string, error) {
+	if e.Auth == "" {
+		return e.Username, e.Password, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(e.Auth)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to decode auth entry: %v", err)
+	}
+	username, password, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return "", "", fmt.Errorf("invalid auth entry format")
+	}
+
+	return username, password, nil
 }
@@ -0,0 +1,117 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeAuthFile writes a docker config.json file and returns its path.
+func writeAuthFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// encodeAuth returns the base64 encoded auth field of the credentials.
+func encodeAuth(username, password string) string {
+	return base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+}
+
+func TestGetCredentials(t *testing.T) {
+	authFile := writeAuthFile(t, `{"auths": {
+		"quay.io": {"auth": "`+encodeAuth("registry", "r")+`"},
+		"quay.io/org": {"auth": "`+encodeAuth("namespace", "n")+`"},
+		"quay.io/org/image": {"auth": "`+encodeAuth("repository", "i")+`"},
+		"https://index.docker.io/v1/": {"auth": "`+encodeAuth("hub", "h")+`"},
+		"https://registry.example.com/v2/": {"username": "plain", "password": "p"},
+		"both.example.com": {"auth": "`+encodeAuth("encoded", "e")+`", "username": "plain", "password": "p"}
+	}}`)
+	auth := NewQuayAuth(authFile)
+
+	tests := []struct {
+		registry, repository string
+		wantUser, wantPass   string
+	}{
+		{"quay.io", "org/image", "repository", "i"},
+		{"quay.io", "org/other", "namespace", "n"},
+		{"quay.io", "org/image/nested", "repository", "i"},
+		{"quay.io", "orgs/image", "registry", "r"},
+		{"quay.io", "other/image", "registry", "r"},
+		{"docker.io", "library/nginx", "hub", "h"},
+		{"registry.example.com", "org/image", "plain", "p"},
+		{"both.example.com", "org/image", "encoded", "e"},
+		{"ghcr.io", "org/image", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.registry+"/"+tt.repository, func(t *testing.T) {
+			user, pass, err := auth.getCredentials(tt.registry, tt.repository)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if user != tt.wantUser || pass != tt.wantPass {
+				t.Errorf("got %s:%s, want %s:%s", user, pass, tt.wantUser, tt.wantPass)
+			}
+		})
+	}
+}
+
+func TestGetCredentialsErrors(t *testing.T) {
+	tests := map[string]string{
+		"missing file":    filepath.Join(t.TempDir(), "missing.json"),
+		"malformed file":  writeAuthFile(t, `{"auths": `),
+		"auth not base64": writeAuthFile(t, `{"auths": {"quay.io": {"auth": "not base64!"}}}`),
+		"auth without colon": writeAuthFile(t,
+			`{"auths": {"quay.io": {"auth": "`+base64.StdEncoding.EncodeToString([]byte("user"))+`"}}}`),
+	}
+	for name, authFile := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := NewQuayAuth(authFile).getCredentials("quay.io", "org/image"); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestGetCredentialsWithoutFile(t *testing.T) {
+	user, pass, err := NewQuayAuth("").getCredentials("quay.io", "org/image")
+	if err != nil || user != "" || pass != "" {
+		t.Errorf("got %q, %q, %v, want no credentials", user, pass, err)
+	}
+}
+
+func TestNormalizeAuthKey(t *testing.T) {
+	tests := map[string]string{
+		"https://index.docker.io/v1/": "docker.io",
+		"index.docker.io":             "docker.io",
+		"registry-1.docker.io":        "docker.io",
+		"docker.io":                   "docker.io",
+		"https://quay.io":             "quay.io",
+		"http://localhost:5000/v2/":   "localhost:5000",
+		"quay.io/org/image":           "quay.io/org/image",
+	}
+	for key, want := range tests {
+		if got := normalizeAuthKey(key); got != want {
+			t.Errorf("normalizeAuthKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/hacbs-release/release-availability-metrics/pkg/metrics"
+)
+
+// COMPOSITE_REASON is the failure reason when the aggregated checks don't satisfy the mode, or didn't run.
+const COMPOSITE_REASON = "checks failed"
+
+// defines the CompositeCheck type.
+type CompositeCheck struct {
+	checkBase
+	checks    []string
+	options   CompositeOptions
+	results   *Results
+	score     metrics.GaugeMetric
+	configErr error
+}
+
+// CompositeOptions holds the optional settings of a CompositeCheck.
+type CompositeOptions struct {
+	// Mode is one of COMPOSITE_MODE_ALL, COMPOSITE_MODE_ANY, COMPOSITE_MODE_AT_LEAST or
+	// COMPOSITE_MODE_WEIGHTED. Defaults to COMPOSITE_MODE_ALL.
+	Mode string
+	// Min is the number of checks which have to succeed in COMPOSITE_MODE_AT_LEAST.
+	Min int
+	// Weights are the weights of the checks in COMPOSITE_MODE_WEIGHTED. Checks default to a weight of 1.
+	Weights map[string]float64
+	// Threshold is the weighted ratio of succeeding checks required in COMPOSITE_MODE_WEIGHTED.
+	Threshold float64
+}
+
+// NewCompositeCheck returns a new instance of CompositeCheck, succeeding when the results of the given
+// checks satisfy the mode. The ratio of succeeding checks, weighted in COMPOSITE_MODE_WEIGHTED, is
+// exported in score.
+func NewCompositeCheck(name string, checks []string, options CompositeOptions, results *Results,
+	labels map[string]string, log *log.Logger, metric metrics.CompositeMetric, score metrics.GaugeMetric,
+) *CompositeCheck {
+	if options.Mode == "" {
+		options.Mode = COMPOSITE_MODE_ALL
+	}
+
+	newCheck := &CompositeCheck{
+		checkBase: checkBase{name: name, labels: metric.LabelValues(name, CHECK_TYPE_COMPOSITE, "", labels),
+			log: log, metric: metric},
+		checks:  checks,
+		options: options,
+		results: results,
+		score:   score,
+	}
+
+	switch options.Mode {
+	case COMPOSITE_MODE_ALL, COMPOSITE_MODE_ANY:
+	case COMPOSITE_MODE_AT_LEAST:
+		if options.Min < 1 || options.Min > len(checks) {
+			newCheck.configErr = fmt.Errorf("at_least requires a min between 1 and %d", len(checks))
+		}
+	case COMPOSITE_MODE_WEIGHTED:
+		if options.Threshold <= 0 || options.Threshold > 1 {
+			newCheck.configErr = fmt.Errorf("weighted requires a threshold between 0 and 1")
+		}
+		for check, weight := range options.Weights {
+			if weight < 0 {
+				newCheck.configErr = fmt.Errorf("negative weight for %s", check)
+			}
+		}
+	default:
+		newCheck.configErr = fmt.Errorf("unknown composite check mode: %s", options.Mode)
+	}
+	if len(checks) == 0 {
+		newCheck.configErr = fmt.Errorf("no checks to aggregate")
+	}
+
+	if newCheck.configErr != nil {
+		log.Printf("[ERROR] %s: %v\n", name, newCheck.configErr)
+	}
+
+	return newCheck
+}
+
+// Dependencies returns the aggregated checks, which have to run before the composite check. Skipped
+// checks count as failed.
+func (c *CompositeCheck) Dependencies() []string {
+	return c.checks
+}
+
+// weight returns the weight of a check, 1 unless set in COMPOSITE_MODE_WEIGHTED.
+func (c *CompositeCheck) weight(check string) float64 {
+	if weight, ok := c.options.Weights[check]; ok && c.options.Mode == COMPOSITE_MODE_WEIGHTED {
+		return weight
+	}
+
+	return 1
+}
+
+// evaluate aggregates the results of the checks as set by the mode.
+func (c *CompositeCheck) evaluate() (CheckResult, error) {
+	if c.configErr != nil {
+		return CheckResult{1, "Failed", failureReason(c.configErr)}, c.configErr
+	}
+
+	succeeded := 0
+	var weight, total float64
+	failed := []string{}
+	for _, check := range c.checks {
+		code, ok := c.results.Get(check)
+		if !ok {
+			err := newCheckError(COMPOSITE_REASON, fmt.Errorf("no result for check %s", check))
+			return CheckResult{1, "Failed", COMPOSITE_REASON}, err
+		}
+		total += c.weight(check)
+		if code == 0 {
+			succeeded++
+			weight += c.weight(check)
+		} else {
+			failed = append(failed, check)
+		}
+	}
+
+	score := 0.0
+	if total > 0 {
+		score = weight / total
+	}
+	c.score.Record(c.labels, score)
+
+	var ok bool
+	switch c.options.Mode {
+	case COMPOSITE_MODE_ALL:
+		ok = succeeded == len(c.checks)
+	case COMPOSITE_MODE_ANY:
+		ok = succeeded > 0
+	case COMPOSITE_MODE_AT_LEAST:
+		ok = succeeded >= c.options.Min
+	case COMPOSITE_MODE_WEIGHTED:
+		ok = score >= c.options.Threshold
+	}
+	if !ok {
+		err := newCheckError(COMPOSITE_REASON, fmt.Errorf("%s", strings.Join(failed, ", ")))
+		return CheckResult{1, "Failed", COMPOSITE_REASON}, err
+	}
+
+	return CheckResult{0, "Succeeded", ""}, nil
+}
+
+// Check evaluates the composite check from the results of the aggregated checks.
+func (c *CompositeCheck) Check(ctx context.Context) (float64, error) {
+	var reason string
+
+	c.log.Println("running composite check:", c.name)
+	res, err := c.evaluate()
+	if err != nil {
+		reason = failureReason(err)
+		c.log.Printf("%s check failed (%s)\n", c.name, err)
+	} else {
+		c.log.Println(c.name, "check succeeded")
+	}
+	c.metric.Record(c.labels, res.code, reason)
+	traceResult(ctx, res.code, reason)
+
+	return res.code, err
+}
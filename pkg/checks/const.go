@@ -17,3 +17,26 @@ package checks
 
 const GITHUB_REPO = 1
 const GITLAB_REPO = 2
+
+// git check modes
+const GIT_MODE_CLONE = "clone"
+const GIT_MODE_LS_REMOTE = "ls-remote"
+const GIT_MODE_SPARSE = "sparse"
+const GIT_MODE_API = "api"
+
+// git check ref types
+const GIT_REF_BRANCH = "branch"
+const GIT_REF_TAG = "tag"
+const GIT_REF_COMMIT = "commit"
+
+// check types, exported in the type label
+const CHECK_TYPE_GIT = "git"
+const CHECK_TYPE_HTTP = "http"
+const CHECK_TYPE_QUAY = "quay"
+const CHECK_TYPE_COMPOSITE = "composite"
+
+// composite check modes
+const COMPOSITE_MODE_ALL = "all"
+const COMPOSITE_MODE_ANY = "any"
+const COMPOSITE_MODE_AT_LEAST = "at_least"
+const COMPOSITE_MODE_WEIGHTED = "weighted"
@@ -17,14 +17,22 @@ package checks
 
 import (
 	"context"
+	"crypto/rsa"
 	"fmt"
 	"io"
 	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/storage/memory"
+	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/hacbs-release/release-availability-metrics/pkg/metrics"
 
@@ -33,112 +41,432 @@ import (
 
 // defines the GitCheck type.
 type GitCheck struct {
+	checkBase
 	prefix   string
-	name     string
 	token    string
 	url      string
 	revision string
-	path     string
-	log      *log.Logger
+	paths    []string
+	options  GitOptions
 	//metric   metrics.GaugeMetric
-	metric metrics.CompositeMetric
+	gitMetric   metrics.GitMetric
+	auth        transport.AuthMethod
+	contentRe   *regexp.Regexp
+	headSHA     string
+	provider    int
+	project     string
+	apiURL      string
+	client      *http.Client
+	cacheDir    string
+	appKey      *rsa.PrivateKey
+	appToken    appToken
+	trustedKeys trustedKeys
+	configErr   error
+	hostKeyErr  error
 }
 
-// NewGitCheck returns a new instance of GitCheck.
-func NewGitCheck(prefix string, name string, token string, url string, revision string, path string,
-	log *log.Logger, metric metrics.CompositeMetric) *GitCheck {
+// GitOptions holds the optional settings of a GitCheck.
+type GitOptions struct {
+	// Mode is one of GIT_MODE_CLONE, GIT_MODE_LS_REMOTE, GIT_MODE_SPARSE or GIT_MODE_API. Defaults to
+	// GIT_MODE_CLONE.
+	Mode string
+	// Provider is the provider used in GIT_MODE_API, github or gitlab. Detected from the url for github.com
+	// and gitlab.com.
+	Provider string
+	// ApiUrl overrides the provider API url, as for GitHub Enterprise or self-managed GitLab instances.
+	ApiUrl string
+	// CacheDir keeps a bare repository per check in the directory and fetches the revision into it
+	// incrementally, instead of cloning on every run. Only used in GIT_MODE_CLONE.
+	CacheDir string
+	// Username is sent along with the token on http urls. Defaults to oauth2.
+	Username string
+	// GithubApp authenticates with short-lived installation tokens of a GitHub App instead of the token.
+	GithubApp *GithubApp
+	// SSHKey is the PEM encoded private key, or the path to one, used for ssh urls.
+	SSHKey string
+	// SSHPassphrase is the passphrase of SSHKey, if encrypted.
+	SSHPassphrase string
+	// KnownHosts holds known hosts data used to verify the ssh host key.
+	KnownHosts string
+	// KnownHostsFile is the path to a known_hosts file used to verify the ssh host key.
+	KnownHostsFile string
+	// RefType restricts the revision to one of GIT_REF_BRANCH, GIT_REF_TAG or GIT_REF_COMMIT. By default
+	// the revision is resolved like git does.
+	RefType string
+	// ContentRegex is a regular expression the file content has to match.
+	ContentRegex string
+	// MaxCommitAge fails the check when the commit of the revision is older. Zero disables the check.
+	MaxCommitAge time.Duration
+	// Sha256 is the expected hex encoded SHA256 of the file content.
+	Sha256 string
+	// YamlPath is a dotted path, like spec.components[0].name, that has to exist in the YAML file.
+	YamlPath string
+	// JsonPath is a dotted path, like spec.components[0].name, that has to exist in the JSON file.
+	JsonPath string
+	// TrustedKeys enables the verification of the commit signature. Each key is an armored GPG public key,
+	// ssh public keys in the authorized_keys format, or the path to a file holding either. Not supported
+	// in GIT_MODE_LS_REMOTE and GIT_MODE_API.
+	TrustedKeys []string
+}
+
+// NewGitCheck returns a new instance of GitCheck. All the paths are checked in a single clone of the
+// repository. The labels are set on every series of the check.
+func NewGitCheck(prefix string, name string, token string, url string, revision string, paths []string,
+	options GitOptions, labels map[string]string, log *log.Logger, metric metrics.CompositeMetric,
+	gitMetric metrics.GitMetric) *GitCheck {
+	if options.Mode == "" {
+		options.Mode = GIT_MODE_CLONE
+	}
+
 	newCheck := &GitCheck{
-		prefix:   prefix,
-		name:     name,
-		token:    token,
-		url:      url,
-		revision: revision,
-		path:     path,
-		log:      log,
-		metric:   metric,
+		checkBase: checkBase{name: name, log: log, metric: metric},
+		prefix:    prefix,
+		token:     token,
+		url:       url,
+		revision:  revision,
+		paths:     paths,
+		options:   options,
+		gitMetric: gitMetric,
 	}
 
-	return newCheck
-}
+	var target string
+	if ep, err := transport.NewEndpoint(url); err == nil {
+		target = strings.ToLower(ep.Host)
+	}
+	newCheck.labels = metric.LabelValues(name, CHECK_TYPE_GIT, target, labels)
 
-// cloneAndGetTree clone a git repository and returns a new instance of object.Tree.
-func (c *GitCheck) cloneAndGetTree(ctx context.Context) (*object.Tree, error) {
-	var tree *object.Tree
+	switch options.Mode {
+	case GIT_MODE_CLONE, GIT_MODE_LS_REMOTE, GIT_MODE_SPARSE, GIT_MODE_API:
+	default:
+		newCheck.configErr = fmt.Errorf("unknown git check mode: %s", options.Mode)
+	}
+	if options.Mode == GIT_MODE_API || options.GithubApp != nil {
+		if err := newCheck.setupAPI(); err != nil {
+			newCheck.configErr = err
+		}
+		newCheck.client = &http.Client{
+			Timeout: 30 * time.Second,
+		}
+	}
+	if options.CacheDir != "" {
+		if options.Mode != GIT_MODE_CLONE {
+			newCheck.configErr = fmt.Errorf("cache dir is not supported in %s mode", options.Mode)
+		}
+		newCheck.cacheDir = cacheDir(options.CacheDir, name, url)
+	}
+	if options.MaxCommitAge != 0 && (options.Mode == GIT_MODE_LS_REMOTE || options.Mode == GIT_MODE_API) {
+		newCheck.configErr = fmt.Errorf("max commit age is not supported in %s mode", options.Mode)
+	}
+
+	if len(options.TrustedKeys) != 0 {
+		if options.Mode == GIT_MODE_LS_REMOTE || options.Mode == GIT_MODE_API {
+			newCheck.configErr = fmt.Errorf("signature verification is not supported in %s mode", options.Mode)
+		}
+		keys, err := loadTrustedKeys(options.TrustedKeys)
+		if err != nil {
+			newCheck.configErr = err
+		}
+		newCheck.trustedKeys = keys
+	}
+
+	switch options.RefType {
+	case "", GIT_REF_BRANCH, GIT_REF_TAG, GIT_REF_COMMIT:
+	default:
+		newCheck.configErr = fmt.Errorf("unknown git ref type: %s", options.RefType)
+	}
+
+	if options.hasContentAssertions() && options.Mode == GIT_MODE_LS_REMOTE {
+		newCheck.configErr = fmt.Errorf("content assertions are not supported in %s mode", options.Mode)
+	}
+	if options.ContentRegex != "" {
+		re, err := regexp.Compile(options.ContentRegex)
+		if err != nil {
+			newCheck.configErr = fmt.Errorf("invalid content regex: %v", err)
+		}
+		newCheck.contentRe = re
+	}
+	for _, path := range []string{options.YamlPath, options.JsonPath} {
+		if _, err := splitPath(path); path != "" && err != nil {
+			newCheck.configErr = err
+		}
+	}
 
-	cloneOptions := &git.CloneOptions{
-		URL:           c.url,
-		ReferenceName: plumbing.ReferenceName(c.revision),
-		Progress:      io.Discard,
-		Depth:         1,
+	if isSSHUrl(url) && options.Mode != GIT_MODE_API {
+		auth, err := newCheck.newSSHAuth(options.SSHKey, options.SSHPassphrase, options.KnownHosts,
+			options.KnownHostsFile)
+		if err != nil {
+			newCheck.configErr = err
+		}
+		newCheck.auth = auth
+	} else if token != "" {
+		username := options.Username
+		if username == "" {
+			username = "oauth2"
+		}
+		newCheck.auth = &githttp.BasicAuth{
+			Username: username,
+			Password: token,
+		}
 	}
 
-	// Only set auth if token is provided
-	if c.token != "" {
-		cloneOptions.Auth = &githttp.BasicAuth{
-			Username: "oauth2",
-			Password: c.token,
+	if options.GithubApp != nil {
+		if isSSHUrl(url) {
+			newCheck.configErr = fmt.Errorf("github app authentication is not supported on ssh urls")
+		} else if newCheck.provider != GITHUB_REPO {
+			newCheck.configErr = fmt.Errorf("github app authentication requires the github provider")
+		}
+		key, err := parseAppKey(options.GithubApp.PrivateKey)
+		if err != nil {
+			newCheck.configErr = err
 		}
+		newCheck.appKey = key
 	}
 
-	r, err := git.CloneContext(ctx, memory.NewStorage(), nil, cloneOptions)
+	if newCheck.configErr != nil {
+		log.Printf("[ERROR] %s: %v\n", name, newCheck.configErr)
+	}
+
+	return newCheck
+}
+
+// labelValues returns the label values of the check followed by the given ones.
+func (c *GitCheck) labelValues(values ...string) []string {
+	return append(append([]string{}, c.labels...), values...)
+}
+
+// getAuth returns the auth method used to connect to the remote, or nil if no credentials are set.
+func (c *GitCheck) getAuth() transport.AuthMethod {
+	return c.auth
+}
+
+// cloneAndGetCommit clone a git repository and returns the commit of the checked revision.
+func (c *GitCheck) cloneAndGetCommit(ctx context.Context) (*object.Commit, error) {
+	session, ar, err := c.openUploadPack(ctx)
 	if err != nil {
 		c.log.Println(err.Error())
-		return tree, err
+		return nil, err
 	}
-
-	ref, err := r.Head()
+	rev, err := c.resolveRevision(ar)
+	session.Close()
 	if err != nil {
 		c.log.Println(err.Error())
-		return tree, err
+		return nil, err
 	}
 
-	commit, err := r.CommitObject(ref.Hash())
+	if c.cacheDir != "" {
+		done := traceStep(ctx, "cache fetch")
+		commit, err := c.cachedCommit(ctx, rev)
+		done(err)
+		return commit, err
+	}
+
+	done := traceStep(ctx, "clone")
+	r, hash, err := c.cloneRevision(ctx, rev)
+	done(err)
 	if err != nil {
 		c.log.Println(err.Error())
-		return tree, err
+		return nil, err
 	}
 
-	tree, err = commit.Tree()
+	commit, err := getCommit(r.Storer, hash)
 	if err != nil {
 		c.log.Println(err.Error())
-		return tree, err
+		return nil, err
 	}
 
-	return tree, nil
+	return commit, nil
 }
 
-// statFile checks the existence of a file in the git repository.
-func (c *GitCheck) statFile(ctx context.Context) (CheckResult, error) {
-	tree, err := c.cloneAndGetTree(ctx)
+// cloneRevision clones the resolved revision with a depth of 1 and returns the repository with the hash
+// of the cloned commit. Commits which are not a reference are fetched by their SHA, which requires the
+// remote to allow it.
+func (c *GitCheck) cloneRevision(ctx context.Context, rev revision) (*git.Repository, plumbing.Hash, error) {
+	if rev.name != "" {
+		cloneOptions := &git.CloneOptions{
+			URL:           c.url,
+			ReferenceName: rev.name,
+			Progress:      io.Discard,
+			Depth:         1,
+			Auth:          c.getAuth(),
+		}
+
+		r, err := git.CloneContext(ctx, memory.NewStorage(), nil, cloneOptions)
+		if err != nil {
+			return nil, plumbing.ZeroHash, err
+		}
+
+		ref, err := r.Head()
+		if err != nil {
+			return nil, plumbing.ZeroHash, err
+		}
+
+		return r, ref.Hash(), nil
+	}
+
+	r, err := git.Init(memory.NewStorage(), nil)
 	if err != nil {
-		c.log.Println(fmt.Sprintf("%s check failed (%s)", c.name, err.Error()))
-		return CheckResult{1, "Failed", err.Error()}, err
+		return nil, plumbing.ZeroHash, err
+	}
+	remote, err := r.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{c.url}})
+	if err != nil {
+		return nil, plumbing.ZeroHash, err
 	}
 
-	_, err = tree.File(c.path)
+	fetchOptions := &git.FetchOptions{
+		RefSpecs: []config.RefSpec{config.RefSpec(rev.hash.String() + ":refs/heads/checked")},
+		Depth:    1,
+		Auth:     c.getAuth(),
+		Progress: io.Discard,
+		Tags:     git.NoTags,
+	}
+	if err := remote.FetchContext(ctx, fetchOptions); err != nil {
+		return nil, plumbing.ZeroHash, err
+	}
+
+	return r, rev.hash, nil
+}
+
+// statFile checks the existence of the files in the git repository. In ls-remote mode only the existence
+// of the revision is checked.
+func (c *GitCheck) statFile(ctx context.Context) (CheckResult, error) {
+	c.hostKeyErr = nil
+
+	err := c.configErr
+	if err == nil && c.options.GithubApp != nil {
+		done := traceStep(ctx, "auth token fetch")
+		err = c.refreshAppToken(ctx)
+		done(err)
+	}
+	if err == nil {
+		err = c.checkRevision(ctx)
+	}
 	if err != nil {
+		err = c.classifyError(err)
 		c.log.Println(fmt.Sprintf("%s check failed (%s)", c.name, err.Error()))
-		return CheckResult{1, "Failed", err.Error()}, err
+		return CheckResult{1, "Failed", failureReason(err)}, err
 	}
 	c.log.Println(c.name, "check succeeded")
 
 	return CheckResult{0, "Succeeded", ""}, nil
 }
 
-// Check runs a check and returns a float64 of the check result. The float64 is required to push values
-// to prometheus.
-func (c *GitCheck) Check(ctx context.Context) float64 {
+// checkRevision fetches the checked revision as set by the check mode and checks the paths in it. The
+// error of the first failing path is returned.
+func (c *GitCheck) checkRevision(ctx context.Context) error {
+	if c.options.Mode == GIT_MODE_LS_REMOTE {
+		hash, err := c.lsRemote(ctx)
+		if err == nil {
+			c.recordHead(hash, nil)
+		}
+		return err
+	}
+
+	if c.options.Mode == GIT_MODE_API {
+		return c.checkPaths(nil, func(path string) error {
+			return c.checkAPIPath(ctx, path)
+		})
+	}
+
+	var commit *object.Commit
+	var err error
+	if c.options.Mode == GIT_MODE_SPARSE {
+		commit, err = c.fetchCommit(ctx)
+	} else {
+		commit, err = c.cloneAndGetCommit(ctx)
+	}
+	var tree *object.Tree
+	if err == nil {
+		c.recordHead(commit.Hash, commit)
+		tree, err = commit.Tree()
+	}
+	if err != nil {
+		for _, path := range c.paths {
+			c.gitMetric.Path.Record(c.labelValues(path), 0)
+		}
+		return err
+	}
+
+	err = c.checkCommitAge(commit)
+	if err == nil && len(c.options.TrustedKeys) != 0 {
+		done := traceStep(ctx, "signature verification")
+		err = c.verifySignature(commit)
+		done(err)
+	}
+
+	return c.checkPaths(err, func(path string) error {
+		return c.checkPath(ctx, tree, path)
+	})
+}
+
+// checkPaths runs the given check on every path and exports the result of each path. The error of the
+// first failing path is returned, unless an error is already given.
+func (c *GitCheck) checkPaths(firstErr error, check func(path string) error) error {
+	for _, path := range c.paths {
+		err := check(path)
+		if err != nil {
+			c.log.Println(fmt.Sprintf("%s check failed for %s (%s)", c.name, path, err.Error()))
+			if firstErr == nil {
+				firstErr = err
+			}
+			c.gitMetric.Path.Record(c.labelValues(path), 0)
+			continue
+		}
+		c.gitMetric.Path.Record(c.labelValues(path), 1)
+	}
+
+	return firstErr
+}
+
+// checkPath stats a path in the tree and evaluates the content assertions on it.
+func (c *GitCheck) checkPath(ctx context.Context, tree *object.Tree, path string) error {
+	done := traceStep(ctx, "tree lookup", attribute.String("path", path))
+	err := c.statPath(tree, path)
+	done(err)
+	if err != nil {
+		return err
+	}
+	if !c.options.hasContentAssertions() {
+		return nil
+	}
+
+	content, err := c.readFile(ctx, tree, path)
+	if err != nil {
+		return err
+	}
+
+	return c.assertContent(path, content)
+}
+
+// statPath checks that the path is a file of the tree. In sparse mode the tree entry is used, as the
+// blobs are not fetched.
+func (c *GitCheck) statPath(tree *object.Tree, path string) error {
+	if c.options.Mode != GIT_MODE_SPARSE {
+		_, err := tree.File(path)
+		return err
+	}
+
+	entry, err := tree.FindEntry(path)
+	if err != nil || !entry.Mode.IsFile() {
+		return object.ErrFileNotFound
+	}
+
+	return nil
+}
+
+// Check runs a check and returns a float64 of the check result, along with the error of a failed run. The
+// float64 is required to push values to prometheus.
+func (c *GitCheck) Check(ctx context.Context) (float64, error) {
 	var reason string
 
 	c.log.Println("running git check:", c.name)
 	res, err := c.statFile(ctx)
 	if err != nil {
-		reason = err.Error()
+		reason = failureReason(err)
 	}
-	c.metric.Gauge.Record([]string{c.name}, metrics.FlipValue(res.code))
-	c.metric.Histogram.Record([]string{c.name, reason, res.status}, 1)
+	c.metric.Record(c.labels, res.code, reason)
+	traceResult(ctx, res.code, reason)
 
-	return res.code
+	return res.code, err
 }
 
 func (c *GitCheck) GetMetric() metrics.CompositeMetric {
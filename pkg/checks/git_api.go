@@ -0,0 +1,227 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	neturl "net/url"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// providers maps the provider names accepted in the configuration to the repository types.
+var providers = map[string]int{
+	"github": GITHUB_REPO,
+	"gitlab": GITLAB_REPO,
+}
+
+// detectProvider returns the repository type of the given provider name, or detects it from the
+// repository host if the name is empty. Only github.com and gitlab.com are detected, self-hosted
+// instances require the provider name. Zero is returned if the provider is unknown.
+func detectProvider(provider, host string) int {
+	if provider != "" {
+		return providers[strings.ToLower(provider)]
+	}
+
+	switch {
+	case isDomain(host, "github.com"):
+		return GITHUB_REPO
+	case isDomain(host, "gitlab.com"):
+		return GITLAB_REPO
+	}
+
+	return 0
+}
+
+// isDomain returns true if host is the domain or one of its subdomains.
+func isDomain(host, domain string) bool {
+	host = strings.ToLower(host)
+
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// setupAPI detects the provider and the API url of the repository for the api mode and the GitHub App
+// authentication.
+func (c *GitCheck) setupAPI() error {
+	ep, err := transport.NewEndpoint(c.url)
+	if err != nil {
+		return err
+	}
+
+	c.provider = detectProvider(c.options.Provider, ep.Host)
+	if c.provider == 0 {
+		return fmt.Errorf("unknown git provider for %s, set the provider", c.url)
+	}
+	c.project = strings.TrimSuffix(strings.Trim(ep.Path, "/"), ".git")
+
+	c.apiURL = strings.TrimSuffix(c.options.ApiUrl, "/")
+	if c.apiURL == "" {
+		switch {
+		case c.provider == GITHUB_REPO && isDomain(ep.Host, "github.com"):
+			c.apiURL = "https://api.github.com"
+		case c.provider == GITHUB_REPO:
+			c.apiURL = fmt.Sprintf("https://%s/api/v3", ep.Host)
+		case isDomain(ep.Host, "gitlab.com"):
+			c.apiURL = "https://gitlab.com/api/v4"
+		default:
+			c.apiURL = fmt.Sprintf("https://%s/api/v4", ep.Host)
+		}
+	}
+
+	return nil
+}
+
+// apiRef returns the revision as expected by the provider APIs, which take branch and tag names
+// without their refs/ prefix. The default branch is used if no revision is set.
+func (c *GitCheck) apiRef() string {
+	ref := strings.TrimPrefix(strings.TrimPrefix(c.revision, "refs/heads/"), "refs/tags/")
+	if ref == "" && c.provider == GITLAB_REPO {
+		return "HEAD"
+	}
+
+	return ref
+}
+
+// newAPIRequest returns a request to the raw content of a file through the provider API.
+func (c *GitCheck) newAPIRequest(ctx context.Context, path string) (*http.Request, error) {
+	query := neturl.Values{}
+	if ref := c.apiRef(); ref != "" {
+		query.Set("ref", ref)
+	}
+
+	var fileURL string
+	if c.provider == GITHUB_REPO {
+		fileURL = fmt.Sprintf("%s/repos/%s/contents/%s", c.apiURL, c.project,
+			escapeSegments(strings.TrimPrefix(path, "/")))
+	} else {
+		fileURL = fmt.Sprintf("%s/projects/%s/repository/files/%s/raw", c.apiURL,
+			neturl.PathEscape(c.project), neturl.PathEscape(strings.TrimPrefix(path, "/")))
+	}
+	if len(query) != 0 {
+		fileURL += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.provider == GITHUB_REPO {
+		req.Header.Set("Accept", "application/vnd.github.raw+json")
+		req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+	} else if c.token != "" {
+		req.Header.Set("PRIVATE-TOKEN", c.token)
+	}
+
+	return req, nil
+}
+
+// escapeSegments escapes each segment of a slash separated path.
+func escapeSegments(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = neturl.PathEscape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// checkAPIPath verifies a file through the contents API of the provider and evaluates the content
+// assertions on it. The rate limit headroom returned by the provider is exported.
+func (c *GitCheck) checkAPIPath(ctx context.Context, path string) error {
+	req, err := c.newAPIRequest(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	done := traceStep(ctx, "api request", attribute.String("path", path))
+	resp, err := c.client.Do(req)
+	done(err)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	c.recordRateLimit(resp.Header)
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		// GitHub lists the entries of a directory as JSON instead of failing, like the tree lookup does
+		if c.provider == GITHUB_REPO && isDirectoryListing(resp.Header) {
+			return object.ErrFileNotFound
+		}
+	case http.StatusNotFound:
+		return object.ErrFileNotFound
+	default:
+		return statusErrorf(resp.StatusCode, "%s API request failed with status: %s", c.providerName(), resp.Status)
+	}
+
+	if !c.options.hasContentAssertions() {
+		return nil
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxBlobSize))
+	if err != nil {
+		return err
+	}
+
+	return c.assertContent(path, content)
+}
+
+// isDirectoryListing returns true if a GitHub contents API response lists a directory. Directories are
+// listed as application/json whatever the requested media type, while files are returned raw.
+func isDirectoryListing(header http.Header) bool {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+
+	return err == nil && mediaType == "application/json"
+}
+
+// recordRateLimit exports the remaining API requests, sent by GitHub as X-RateLimit-Remaining and by
+// GitLab as RateLimit-Remaining.
+func (c *GitCheck) recordRateLimit(header http.Header) {
+	remaining := header.Get("X-RateLimit-Remaining")
+	if remaining == "" {
+		remaining = header.Get("RateLimit-Remaining")
+	}
+
+	value, err := strconv.ParseFloat(remaining, 64)
+	if err != nil {
+		return
+	}
+	c.gitMetric.RateLimitRemaining.Record(c.labelValues(), value)
+}
+
+// providerName returns the name of the provider of the repository.
+func (c *GitCheck) providerName() string {
+	for name, provider := range providers {
+		if provider == c.provider {
+			return name
+		}
+	}
+
+	return "unknown"
+}
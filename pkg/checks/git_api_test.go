@@ -0,0 +1,186 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/hacbs-release/release-availability-metrics/pkg/metrics"
+)
+
+// fakeProvider serves the file contents of a repository through the GitHub or GitLab API.
+type fakeProvider struct {
+	// rawPath is the escaped request path the file is served at
+	rawPath string
+	// dirPath is the escaped request path listed as a directory, as GitHub does
+	dirPath string
+	// header is the rate limit header of the provider
+	header string
+	token  string
+}
+
+func (p fakeProvider) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Header.Get("Authorization") == "Bearer limited" || req.Header.Get("PRIVATE-TOKEN") == "limited" {
+		w.Header().Set(p.header, "0")
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	w.Header().Set(p.header, "4999")
+	if req.Header.Get("Authorization") != "Bearer "+p.token && req.Header.Get("PRIVATE-TOKEN") != p.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if p.dirPath != "" && req.URL.EscapedPath() == p.dirPath {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		fmt.Fprint(w, `[{"type": "file", "name": "a b#c?.yaml"}]`)
+		return
+	}
+	if req.URL.EscapedPath() != p.rawPath || req.URL.Query().Get("ref") != "main" {
+		http.NotFound(w, req)
+		return
+	}
+	fmt.Fprint(w, "version: 1\n")
+}
+
+// newAPICheck returns an api mode check of the given path of org/repo.
+func newAPICheck(provider, apiURL, token, path string, options GitOptions) *GitCheck {
+	options.Mode = GIT_MODE_API
+	options.Provider = provider
+	options.ApiUrl = apiURL
+
+	return NewGitCheck("test", "api", token, "https://git.example.com/org/repo.git", "refs/heads/main",
+		[]string{path}, options, nil, log.New(io.Discard, "", 0), metrics.NewCompositeMetric("test", nil),
+		metrics.NewGitMetric("test", nil))
+}
+
+// gaugeValue returns the value of a gauge series.
+func gaugeValue(t *testing.T, gauge metrics.GaugeMetric, labels []string) float64 {
+	m := &dto.Metric{}
+	if err := gauge.Metric.WithLabelValues(labels...).Write(m); err != nil {
+		t.Fatal(err)
+	}
+
+	return m.GetGauge().GetValue()
+}
+
+func TestGitCheckAPI(t *testing.T) {
+	providers := []struct {
+		name   string
+		server fakeProvider
+	}{
+		{"github", fakeProvider{
+			rawPath: "/repos/org/repo/contents/dir/a%20b%23c%3F.yaml",
+			dirPath: "/repos/org/repo/contents/dir",
+			header:  "X-RateLimit-Remaining",
+			token:   "token",
+		}},
+		{"gitlab", fakeProvider{
+			rawPath: "/projects/org%2Frepo/repository/files/dir%2Fa%20b%23c%3F.yaml/raw",
+			header:  "RateLimit-Remaining",
+			token:   "token",
+		}},
+	}
+	for _, provider := range providers {
+		server := httptest.NewServer(provider.server)
+		defer server.Close()
+
+		t.Run(provider.name+" found", func(t *testing.T) {
+			check := newAPICheck(provider.name, server.URL, "token", "dir/a b#c?.yaml",
+				GitOptions{ContentRegex: "version: 1"})
+			if _, err := check.Check(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if value := gaugeValue(t, check.gitMetric.RateLimitRemaining, check.labelValues()); value != 4999 {
+				t.Errorf("rate limit remaining = %v, want 4999", value)
+			}
+		})
+		t.Run(provider.name+" not found", func(t *testing.T) {
+			check := newAPICheck(provider.name, server.URL, "token", "dir/missing.yaml", GitOptions{})
+			if _, err := check.Check(context.Background()); !errors.Is(err, object.ErrFileNotFound) {
+				t.Errorf("got %v, want %v", err, object.ErrFileNotFound)
+			}
+		})
+		t.Run(provider.name+" directory", func(t *testing.T) {
+			check := newAPICheck(provider.name, server.URL, "token", "dir", GitOptions{})
+			if _, err := check.Check(context.Background()); !errors.Is(err, object.ErrFileNotFound) {
+				t.Errorf("got %v, want %v", err, object.ErrFileNotFound)
+			}
+		})
+		t.Run(provider.name+" unauthorized", func(t *testing.T) {
+			check := newAPICheck(provider.name, server.URL, "wrong", "dir/a b#c?.yaml", GitOptions{})
+			if _, err := check.Check(context.Background()); err == nil {
+				t.Error("expected the request to fail")
+			}
+			if value := gaugeValue(t, check.gitMetric.RateLimitRemaining, check.labelValues()); value != 4999 {
+				t.Errorf("rate limit remaining = %v, want 4999", value)
+			}
+		})
+		t.Run(provider.name+" rate limited", func(t *testing.T) {
+			check := newAPICheck(provider.name, server.URL, "limited", "dir/a b#c?.yaml", GitOptions{})
+			if _, err := check.Check(context.Background()); err == nil {
+				t.Error("expected the request to fail")
+			}
+			if value := gaugeValue(t, check.gitMetric.RateLimitRemaining, check.labelValues()); value != 0 {
+				t.Errorf("rate limit remaining = %v, want 0", value)
+			}
+		})
+	}
+}
+
+func TestDetectProvider(t *testing.T) {
+	tests := []struct {
+		provider string
+		host     string
+		want     int
+	}{
+		{"", "github.com", GITHUB_REPO},
+		{"", "GitHub.com", GITHUB_REPO},
+		{"", "www.github.com", GITHUB_REPO},
+		{"", "gitlab.com", GITLAB_REPO},
+		{"", "notgithub.example.com", 0},
+		{"", "github-mirror.corp", 0},
+		{"", "evilgithub.com", 0},
+		{"", "github.com.example.com", 0},
+		{"", "gitlab.example.com", 0},
+		{"github", "git.example.com", GITHUB_REPO},
+		{"GitLab", "git.example.com", GITLAB_REPO},
+		{"bitbucket", "github.com", 0},
+	}
+	for _, tt := range tests {
+		if got := detectProvider(tt.provider, tt.host); got != tt.want {
+			t.Errorf("detectProvider(%q, %q) = %d, want %d", tt.provider, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestGitCheckAPISelfHostedRequiresProvider(t *testing.T) {
+	check := NewGitCheck("test", "api", "", "https://github-mirror.corp/org/repo.git", "main",
+		[]string{"README.md"}, GitOptions{Mode: GIT_MODE_API}, nil, log.New(io.Discard, "", 0),
+		metrics.NewCompositeMetric("test", nil), metrics.NewGitMetric("test", nil))
+	if check.configErr == nil {
+		t.Error("got no configuration error for a self-hosted instance without provider")
+	}
+}
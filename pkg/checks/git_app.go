@@ -0,0 +1,176 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+)
+
+const (
+	// APP_TOKEN_REASON is the failure reason when no GitHub App installation token could be minted.
+	APP_TOKEN_REASON = "app token failed"
+	// appTokenUsername is the username GitHub expects along with installation tokens.
+	appTokenUsername = "x-access-token"
+	// appTokenRefreshMargin is how long before its expiry an installation token is replaced.
+	appTokenRefreshMargin = 5 * time.Minute
+)
+
+// GithubApp holds the GitHub App installation a GitCheck authenticates as.
+type GithubApp struct {
+	AppId          int64
+	InstallationId int64
+	// PrivateKey is the PEM encoded private key of the app, or the path to one.
+	PrivateKey string
+}
+
+// appToken is an installation token along with its expiry.
+type appToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// parseAppKey parses the RSA private key of a GitHub App, in PKCS#1 or PKCS#8 form.
+func parseAppKey(value string) (*rsa.PrivateKey, error) {
+	data, err := loadPEM(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read github app private key: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in github app private key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid github app private key: %v", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("github app private key is not an RSA key")
+	}
+
+	return rsaKey, nil
+}
+
+// appJWT returns the RS256 signed JWT identifying the app. It is backdated by a minute to allow for
+// clock drift, and valid for ten minutes at most as required by GitHub.
+func (c *GitCheck) appJWT(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": strconv.FormatInt(c.options.GithubApp.AppId, 10),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	encoding := base64.RawURLEncoding
+	unsigned := encoding.EncodeToString(header) + "." + encoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.appKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + encoding.EncodeToString(signature), nil
+}
+
+// mintAppToken requests a new installation token from the GitHub API.
+func (c *GitCheck) mintAppToken(ctx context.Context) (appToken, error) {
+	jwt, err := c.appJWT(time.Now())
+	if err != nil {
+		return appToken{}, err
+	}
+
+	tokenURL := fmt.Sprintf("%s/app/installations/%d/access_tokens", c.apiURL, c.options.GithubApp.InstallationId)
+	req, err := http.NewRequestWithContext(ctx, "POST", tokenURL, nil)
+	if err != nil {
+		return appToken{}, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("Authorization", "Bearer "+jwt)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return appToken{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return appToken{}, err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return appToken{}, statusErrorf(resp.StatusCode, "unexpected status code %d from %s", resp.StatusCode, tokenURL)
+	}
+
+	var result struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return appToken{}, fmt.Errorf("invalid installation token response: %v", err)
+	}
+	if result.Token == "" {
+		return appToken{}, fmt.Errorf("no installation token returned by %s", tokenURL)
+	}
+
+	return appToken{token: result.Token, expiresAt: result.ExpiresAt}, nil
+}
+
+// refreshAppToken mints a new installation token when the current one is missing or about to expire,
+// and sets it as the credentials of the check.
+func (c *GitCheck) refreshAppToken(ctx context.Context) error {
+	if c.appToken.token != "" && time.Until(c.appToken.expiresAt) > appTokenRefreshMargin {
+		return nil
+	}
+
+	token, err := c.mintAppToken(ctx)
+	if err != nil {
+		return newCheckError(APP_TOKEN_REASON, err)
+	}
+	c.appToken = token
+	c.token = token.token
+	c.auth = &githttp.BasicAuth{
+		Username: appTokenUsername,
+		Password: token.token,
+	}
+
+	return nil
+}
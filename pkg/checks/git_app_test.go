@@ -0,0 +1,225 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+
+	"github.com/hacbs-release/release-availability-metrics/pkg/metrics"
+)
+
+// appClaims are the claims of the app JWT.
+type appClaims struct {
+	Iat int64  `json:"iat"`
+	Exp int64  `json:"exp"`
+	Iss string `json:"iss"`
+}
+
+// verifyAppJWT verifies the RS256 signature of a JWT and returns its claims.
+func verifyAppJWT(jwt string, key *rsa.PublicKey) (appClaims, error) {
+	parts := strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		return appClaims{}, fmt.Errorf("malformed JWT")
+	}
+
+	var header map[string]string
+	var claims appClaims
+	for i, v := range []interface{}{&header, &claims} {
+		data, err := base64.RawURLEncoding.DecodeString(parts[i])
+		if err != nil {
+			return appClaims{}, err
+		}
+		if err := json.Unmarshal(data, v); err != nil {
+			return appClaims{}, err
+		}
+	}
+	if header["alg"] != "RS256" {
+		return appClaims{}, fmt.Errorf("unexpected JWT algorithm %s", header["alg"])
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return appClaims{}, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	return claims, rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature)
+}
+
+// fakeGithubApp mints installation tokens for installation 42 of app 7, expiring after ttl.
+type fakeGithubApp struct {
+	key *rsa.PublicKey
+	ttl time.Duration
+
+	mu     sync.Mutex
+	minted int
+}
+
+func (a *fakeGithubApp) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost || req.URL.Path != "/app/installations/42/access_tokens" {
+		http.NotFound(w, req)
+		return
+	}
+	claims, err := verifyAppJWT(strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer "), a.key)
+	if err != nil || claims.Iss != "7" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	a.mu.Lock()
+	a.minted++
+	token := fmt.Sprintf("token-%d", a.minted)
+	a.mu.Unlock()
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":      token,
+		"expires_at": time.Now().Add(a.ttl).UTC().Format(time.RFC3339),
+	})
+}
+
+// newAppKey generates the private key of a test app, PEM encoded in PKCS#1 or PKCS#8 form.
+func newAppKey(t *testing.T, pkcs8 bool) (*rsa.PrivateKey, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if pkcs8 {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+
+	return key, string(pem.EncodeToMemory(block))
+}
+
+// newAppCheck returns a check authenticating as the test app against the API at apiURL.
+func newAppCheck(t *testing.T, apiURL, privateKey string) *GitCheck {
+	check := NewGitCheck("test", "app", "", "https://github.example.com/org/repo.git", "main", []string{"README.md"},
+		GitOptions{
+			Provider:  "github",
+			ApiUrl:    apiURL,
+			GithubApp: &GithubApp{AppId: 7, InstallationId: 42, PrivateKey: privateKey},
+		}, nil, log.New(io.Discard, "", 0), metrics.NewCompositeMetric("test", nil),
+		metrics.NewGitMetric("test", nil))
+	if check.configErr != nil {
+		t.Fatal(check.configErr)
+	}
+
+	return check
+}
+
+func TestAppJWT(t *testing.T) {
+	for _, pkcs8 := range []bool{false, true} {
+		key, privateKey := newAppKey(t, pkcs8)
+		check := newAppCheck(t, "https://api.example.com", privateKey)
+
+		now := time.Now()
+		jwt, err := check.appJWT(now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		claims, err := verifyAppJWT(jwt, &key.PublicKey)
+		if err != nil {
+			t.Fatalf("invalid JWT signature: %v", err)
+		}
+		if claims.Iss != "7" {
+			t.Errorf("iss = %q, want 7", claims.Iss)
+		}
+		if claims.Iat >= now.Unix() {
+			t.Errorf("iat = %d is not backdated from %d", claims.Iat, now.Unix())
+		}
+		if claims.Exp <= now.Unix() || claims.Exp-claims.Iat > int64((10*time.Minute).Seconds()) {
+			t.Errorf("exp = %d, iat = %d: the JWT must expire within 10 minutes", claims.Exp, claims.Iat)
+		}
+	}
+}
+
+func TestRefreshAppToken(t *testing.T) {
+	key, privateKey := newAppKey(t, false)
+	app := &fakeGithubApp{key: &key.PublicKey, ttl: time.Hour}
+	server := httptest.NewServer(app)
+	defer server.Close()
+
+	check := newAppCheck(t, server.URL, privateKey)
+	for i := 0; i < 2; i++ {
+		if err := check.refreshAppToken(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if app.minted != 1 {
+		t.Errorf("minted %d tokens, want the token to be reused until it is about to expire", app.minted)
+	}
+	if check.token != "token-1" {
+		t.Errorf("token = %q, want token-1", check.token)
+	}
+	if auth, ok := check.auth.(*githttp.BasicAuth); !ok || auth.Username != appTokenUsername ||
+		auth.Password != "token-1" {
+		t.Errorf("auth = %#v, want the installation token", check.auth)
+	}
+
+	// a token expiring within the refresh margin is replaced
+	check.appToken.expiresAt = time.Now().Add(appTokenRefreshMargin - time.Minute)
+	if err := check.refreshAppToken(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if app.minted != 2 || check.token != "token-2" {
+		t.Errorf("minted %d tokens, token = %q, want a new token before expiry", app.minted, check.token)
+	}
+}
+
+func TestRefreshAppTokenRejected(t *testing.T) {
+	key, _ := newAppKey(t, false)
+	server := httptest.NewServer(&fakeGithubApp{key: &key.PublicKey, ttl: time.Hour})
+	defer server.Close()
+
+	// the app is registered with another key
+	_, privateKey := newAppKey(t, false)
+	check := newAppCheck(t, server.URL, privateKey)
+	err := check.refreshAppToken(context.Background())
+	if err == nil {
+		t.Fatal("expected the token exchange to fail")
+	}
+	if reason := failureReason(err); reason != APP_TOKEN_REASON {
+		t.Errorf("reason = %q, want %q", reason, APP_TOKEN_REASON)
+	}
+	if check.token != "" {
+		t.Errorf("token = %q, want none", check.token)
+	}
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/format/idxfile"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+const (
+	// cacheRef is the reference the checked revision is fetched to in the cache.
+	cacheRef = "refs/checked/revision"
+	// cacheMaxPacks is the number of packfiles above which the cache is fetched again from scratch. Each
+	// fetch of a new revision adds a packfile, holding objects which stay in the cache otherwise.
+	cacheMaxPacks = 10
+)
+
+// cacheError is an error of the cache itself, as opposed to an error of the remote.
+type cacheError struct {
+	err error
+}
+
+// Error returns the error message.
+func (e *cacheError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *cacheError) Unwrap() error {
+	return e.err
+}
+
+// cacheDir returns the directory of the cache of a check: a hash of its name and url under the cache
+// directory, so that no name can point outside of it and a new url gets a new cache.
+func cacheDir(dir, name, url string) string {
+	sum := sha256.Sum256([]byte(name + "\x00" + url))
+
+	return filepath.Join(dir, hex.EncodeToString(sum[:16]))
+}
+
+// cachedCommit fetches the resolved revision into the bare repository kept in the cache directory and
+// returns its commit. Only the objects missing from the cache are fetched. The cache is deleted and
+// fetched from scratch if it can't be opened or is corrupted, the errors of the remote being returned
+// as is.
+func (c *GitCheck) cachedCommit(ctx context.Context, rev revision) (*object.Commit, error) {
+	commit, err := c.fetchIntoCache(ctx, rev)
+	var cacheErr *cacheError
+	if errors.As(err, &cacheErr) {
+		c.log.Printf("%s: cache in %s not usable, fetching from scratch (%v)\n", c.name, c.cacheDir, err)
+		if err := c.removeCache(); err != nil {
+			return nil, err
+		}
+		commit, err = c.fetchIntoCache(ctx, rev)
+	}
+	c.recordCacheSize()
+
+	return commit, err
+}
+
+// fetchIntoCache opens, or initializes, the cached bare repository and fetches the resolved revision
+// into it. The errors of the cache are returned as a cacheError.
+func (c *GitCheck) fetchIntoCache(ctx context.Context, rev revision) (*object.Commit, error) {
+	r, err := git.PlainOpen(c.cacheDir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		r, err = git.PlainInit(c.cacheDir, true)
+	}
+	if err != nil {
+		return nil, &cacheError{err}
+	}
+	// the objects of the previous revisions are dropped along with the cache
+	packs, err := cachePacks(r)
+	if err != nil {
+		return nil, &cacheError{err}
+	}
+	if packs > cacheMaxPacks {
+		return nil, &cacheError{fmt.Errorf("pruning the %d packfiles of the cache", packs)}
+	}
+
+	remote, err := r.Remote(git.DefaultRemoteName)
+	if errors.Is(err, git.ErrRemoteNotFound) {
+		remote, err = r.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{c.url}})
+	}
+	if err != nil {
+		return nil, &cacheError{err}
+	}
+	if urls := remote.Config().URLs; len(urls) != 1 || urls[0] != c.url {
+		return nil, &cacheError{fmt.Errorf("cached remote %v differs from %s", urls, c.url)}
+	}
+
+	src := rev.hash.String()
+	if rev.name != "" {
+		src = "+" + rev.name.String()
+	}
+	fetchOptions := &git.FetchOptions{
+		RefSpecs: []config.RefSpec{config.RefSpec(src + ":" + cacheRef)},
+		Depth:    1,
+		Auth:     c.getAuth(),
+		Progress: io.Discard,
+		Tags:     git.NoTags,
+		Force:    true,
+	}
+	err = remote.FetchContext(ctx, fetchOptions)
+	if isCorruption(err) {
+		return nil, &cacheError{err}
+	}
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return nil, err
+	}
+	// the commit was just fetched, failing to read it means the cache is broken
+	commit, err := getCommit(r.Storer, rev.hash)
+	if err != nil {
+		return nil, &cacheError{err}
+	}
+
+	return commit, nil
+}
+
+// removeCache deletes the cache directory, refusing to delete anything which is not strictly under the
+// configured cache directory.
+func (c *GitCheck) removeCache() error {
+	rel, err := filepath.Rel(c.options.CacheDir, c.cacheDir)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to delete %s, outside of the cache directory %s", c.cacheDir, c.options.CacheDir)
+	}
+
+	return os.RemoveAll(c.cacheDir)
+}
+
+// isCorruption tells whether a fetch error comes from the packfiles of the cache which can't be read.
+// Objects missing from the remote, like a force-pushed revision, are not a corruption of the cache.
+func isCorruption(err error) bool {
+	var packErr *packfile.Error
+	return errors.As(err, &packErr) || errors.Is(err, packfile.ErrReferenceDeltaNotFound) ||
+		errors.Is(err, packfile.ErrInvalidDelta) || errors.Is(err, packfile.ErrDeltaCmd) ||
+		errors.Is(err, packfile.ErrMalformedPackFile) || errors.Is(err, idxfile.ErrMalformedIdxFile)
+}
+
+// cachePacks returns the number of packfiles of the cache.
+func cachePacks(r *git.Repository) (int, error) {
+	packs, ok := r.Storer.(storer.PackedObjectStorer)
+	if !ok {
+		return 0, nil
+	}
+	hashes, err := packs.ObjectPacks()
+
+	return len(hashes), err
+}
+
+// recordCacheSize exports the size of the cache directory.
+func (c *GitCheck) recordCacheSize() {
+	var size int64
+	err := filepath.WalkDir(c.cacheDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		c.log.Printf("%s: failed to compute the cache size (%v)\n", c.name, err)
+		return
+	}
+
+	c.gitMetric.CacheSize.Record(c.labelValues(), float64(size))
+}
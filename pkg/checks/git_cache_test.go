@@ -0,0 +1,199 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/hacbs-release/release-availability-metrics/pkg/metrics"
+)
+
+// testRepository is a repository the git checks of the tests run against.
+type testRepository struct {
+	t   *testing.T
+	dir string
+	r   *git.Repository
+}
+
+// newTestRepository creates a repository with a README.md commit on master. Its file:// url is served
+// by git-upload-pack, the test is skipped if git is missing.
+func newTestRepository(t *testing.T) *testRepository {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is required to serve the test repository")
+	}
+
+	dir := t.TempDir()
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &testRepository{t: t, dir: dir, r: r}
+	repo.commit("README.md", "readme")
+
+	return repo
+}
+
+// url returns the file:// url of the repository.
+func (repo *testRepository) url() string {
+	return "file://" + repo.dir
+}
+
+// commit commits a file with the given content and returns the commit hash.
+func (repo *testRepository) commit(path, content string) plumbing.Hash {
+	t := repo.t
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(repo.dir, path)), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(repo.dir, path), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := repo.r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add(path); err != nil {
+		t.Fatal(err)
+	}
+	signature := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	hash, err := w.Commit("update "+path, &git.CommitOptions{Author: signature})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hash
+}
+
+// newCacheCheck returns a check of README.md on master keeping its cache in cacheDir.
+func newCacheCheck(url, cacheDir string) *GitCheck {
+	return NewGitCheck("test", "cached", "", url, "master", []string{"README.md"},
+		GitOptions{CacheDir: cacheDir}, nil, log.New(io.Discard, "", 0), metrics.NewCompositeMetric("test", nil),
+		metrics.NewGitMetric("test", nil))
+}
+
+// packs returns the packfiles of a cache.
+func packs(t *testing.T, check *GitCheck) []string {
+	files, err := filepath.Glob(filepath.Join(check.cacheDir, "objects", "pack", "*.pack"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
+
+func TestCacheKeptOnRemoteErrors(t *testing.T) {
+	repo := newTestRepository(t)
+	check := newCacheCheck(repo.url(), t.TempDir())
+	if _, err := check.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	cached := packs(t, check)
+	if len(cached) == 0 {
+		t.Fatal("nothing fetched into the cache")
+	}
+
+	// the remote goes away while the revision is fetched
+	hash := repo.commit("README.md", "updated")
+	if err := os.Rename(repo.dir, repo.dir+".moved"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Rename(repo.dir+".moved", repo.dir)
+	_, err := check.cachedCommit(context.Background(), revision{name: plumbing.NewBranchReferenceName("master"),
+		hash: hash})
+	var cacheErr *cacheError
+	if err == nil || errors.As(err, &cacheErr) {
+		t.Fatalf("got %v, want the error of the remote", err)
+	}
+	for _, pack := range cached {
+		if _, err := os.Stat(pack); err != nil {
+			t.Errorf("cache deleted on a remote error: %v", err)
+		}
+	}
+}
+
+func TestCacheRecreatedWhenCorrupted(t *testing.T) {
+	repo := newTestRepository(t)
+	check := newCacheCheck(repo.url(), t.TempDir())
+	if _, err := check.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	for _, pack := range packs(t, check) {
+		if err := os.WriteFile(pack, []byte("garbage"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := check.Check(context.Background()); err != nil {
+		t.Fatalf("corrupted cache not fetched again: %v", err)
+	}
+}
+
+func TestCacheRecreatedWhenUrlChanges(t *testing.T) {
+	cacheDir := t.TempDir()
+	if _, err := newCacheCheck(newTestRepository(t).url(), cacheDir).Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := newCacheCheck(newTestRepository(t).url(), cacheDir).Check(context.Background()); err != nil {
+		t.Fatalf("cache of another url not fetched again: %v", err)
+	}
+}
+
+func TestCachePruned(t *testing.T) {
+	repo := newTestRepository(t)
+	check := newCacheCheck(repo.url(), t.TempDir())
+	for i := 0; i < cacheMaxPacks+5; i++ {
+		repo.commit("README.md", time.Now().String())
+		if _, err := check.Check(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := len(packs(t, check)); n > cacheMaxPacks {
+		t.Errorf("cache holds %d packfiles, want at most %d", n, cacheMaxPacks)
+	}
+}
+
+func TestCacheDirUnderCacheDir(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"", ".", "..", "../other", "a/b"} {
+		if dir := cacheDir(root, name, "https://github.com/org/repo"); filepath.Dir(dir) != root {
+			t.Errorf("cache of the %q check in %s, outside of %s", name, dir, root)
+		}
+	}
+
+	check := newCacheCheck("https://github.com/org/repo", root)
+	for _, dir := range []string{root, filepath.Dir(root), filepath.Join(root, "..", "other")} {
+		check.cacheDir = dir
+		if err := check.removeCache(); err == nil {
+			t.Errorf("removed %s, not under %s", dir, root)
+		}
+	}
+	if _, err := os.Stat(root); err != nil {
+		t.Fatal(err)
+	}
+}
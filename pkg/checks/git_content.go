@@ -0,0 +1,170 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"gopkg.in/yaml.v3"
+)
+
+// CONTENT_REASON is the failure reason reported when the file content doesn't match the assertions.
+const CONTENT_REASON = "content mismatch"
+
+// maxBlobSize limits the size of a file read for the content assertions.
+const maxBlobSize = 16 * 1024 * 1024
+
+var pathSegmentRe = regexp.MustCompile(`^([^\[\]]*)((?:\[\d+\])*)$`)
+
+// hasContentAssertions returns true if any content assertion is set.
+func (o GitOptions) hasContentAssertions() bool {
+	return o.ContentRegex != "" || o.Sha256 != "" || o.YamlPath != "" || o.JsonPath != ""
+}
+
+// readFile returns the content of a file of the tree. In sparse mode the blob is fetched on its own, as
+// it was left out of the tree fetch.
+func (c *GitCheck) readFile(ctx context.Context, tree *object.Tree, path string) ([]byte, error) {
+	if c.options.Mode == GIT_MODE_SPARSE {
+		entry, err := tree.FindEntry(path)
+		if err != nil {
+			return nil, err
+		}
+		return c.fetchBlob(ctx, entry.Hash)
+	}
+
+	file, err := tree.File(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return readBlob(&file.Blob)
+}
+
+// readBlob returns the content of a blob.
+func readBlob(blob *object.Blob) ([]byte, error) {
+	if blob.Size > maxBlobSize {
+		return nil, fmt.Errorf("file larger than %d bytes", maxBlobSize)
+	}
+
+	reader, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	return io.ReadAll(reader)
+}
+
+// assertContent evaluates the content assertions of the check on the content of the file at path. Failed
+// assertions are reported with the CONTENT_REASON reason.
+func (c *GitCheck) assertContent(path string, content []byte) error {
+	if c.contentRe != nil && !c.contentRe.Match(content) {
+		return newCheckError(CONTENT_REASON, fmt.Errorf("%s does not match %s", path, c.options.ContentRegex))
+	}
+
+	if c.options.Sha256 != "" {
+		sum := sha256.Sum256(content)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), c.options.Sha256) {
+			return newCheckError(CONTENT_REASON, fmt.Errorf("%s sha256 is %x", path, sum))
+		}
+	}
+
+	if c.options.YamlPath != "" {
+		var doc interface{}
+		if err := yaml.Unmarshal(content, &doc); err != nil {
+			return newCheckError(CONTENT_REASON, fmt.Errorf("%s is not valid YAML: %v", path, err))
+		}
+		if _, err := lookupPath(doc, c.options.YamlPath); err != nil {
+			return newCheckError(CONTENT_REASON, err)
+		}
+	}
+
+	if c.options.JsonPath != "" {
+		var doc interface{}
+		if err := json.Unmarshal(content, &doc); err != nil {
+			return newCheckError(CONTENT_REASON, fmt.Errorf("%s is not valid JSON: %v", path, err))
+		}
+		if _, err := lookupPath(doc, c.options.JsonPath); err != nil {
+			return newCheckError(CONTENT_REASON, err)
+		}
+	}
+
+	return nil
+}
+
+// splitPath splits a dotted path like spec.components[0].name into its segments. A leading $ or . is
+// ignored, empty segments, like in a..b or a trailing ., are rejected.
+func splitPath(path string) ([]string, error) {
+	trimmed := strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if trimmed == "" {
+		return nil, nil
+	}
+
+	segments := strings.Split(trimmed, ".")
+	for _, segment := range segments {
+		if segment == "" {
+			return nil, fmt.Errorf("empty segment in path %s", path)
+		}
+		if !pathSegmentRe.MatchString(segment) {
+			return nil, fmt.Errorf("invalid path segment %q in %s", segment, path)
+		}
+	}
+
+	return segments, nil
+}
+
+// lookupPath returns the value at a dotted path like spec.components[0].name in a decoded document.
+func lookupPath(doc interface{}, path string) (interface{}, error) {
+	segments, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	value := doc
+	for _, segment := range segments {
+		parts := pathSegmentRe.FindStringSubmatch(segment)
+
+		if parts[1] != "" {
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s: %q is not a mapping key", path, parts[1])
+			}
+			if value, ok = m[parts[1]]; !ok {
+				return nil, fmt.Errorf("%s: key %q not found", path, parts[1])
+			}
+		}
+
+		for _, index := range strings.FieldsFunc(parts[2], func(r rune) bool { return r == '[' || r == ']' }) {
+			i, _ := strconv.Atoi(index)
+			list, ok := value.([]interface{})
+			if !ok || i >= len(list) {
+				return nil, fmt.Errorf("%s: index %d not found", path, i)
+			}
+			value = list[i]
+		}
+	}
+
+	return value, nil
+}
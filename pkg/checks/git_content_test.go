@@ -0,0 +1,114 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/hacbs-release/release-availability-metrics/pkg/metrics"
+)
+
+const (
+	yamlContent = `
+spec:
+  components:
+    - name: release-service
+      image: quay.io/org/release-service
+    - name: operator
+`
+	jsonContent = `{"spec": {"components": [{"name": "release-service"}, {"name": "operator"}]}}`
+)
+
+// newContentCheck returns a check of config.yaml on master with the content assertions of the options.
+func newContentCheck(url string, options GitOptions) *GitCheck {
+	return NewGitCheck("test", "content", "", url, "master", []string{"config.yaml"}, options, nil,
+		log.New(io.Discard, "", 0), metrics.NewCompositeMetric("test", nil), metrics.NewGitMetric("test", nil))
+}
+
+func TestAssertContent(t *testing.T) {
+	sum := sha256.Sum256([]byte(yamlContent))
+	tests := []struct {
+		name    string
+		options GitOptions
+		content string
+		wantErr bool
+	}{
+		{"regex match", GitOptions{ContentRegex: `name: release-\w+`}, yamlContent, false},
+		{"regex mismatch", GitOptions{ContentRegex: `^kind: Deployment`}, yamlContent, true},
+		{"sha256 match", GitOptions{Sha256: hex.EncodeToString(sum[:])}, yamlContent, false},
+		{"sha256 uppercase", GitOptions{Sha256: strings.ToUpper(hex.EncodeToString(sum[:]))}, yamlContent, false},
+		{"sha256 mismatch", GitOptions{Sha256: hex.EncodeToString(make([]byte, 32))}, yamlContent, true},
+		{"yaml path", GitOptions{YamlPath: "spec.components[1].name"}, yamlContent, false},
+		{"yaml path with $", GitOptions{YamlPath: "$.spec.components[0].image"}, yamlContent, false},
+		{"yaml missing key", GitOptions{YamlPath: "spec.components[1].image"}, yamlContent, true},
+		{"yaml index out of range", GitOptions{YamlPath: "spec.components[2]"}, yamlContent, true},
+		{"yaml index into a mapping", GitOptions{YamlPath: "spec[0]"}, yamlContent, true},
+		{"invalid yaml", GitOptions{YamlPath: "spec"}, "spec: [", true},
+		{"json path", GitOptions{JsonPath: "spec.components[0].name"}, jsonContent, false},
+		{"json missing key", GitOptions{JsonPath: "spec.version"}, jsonContent, true},
+		{"json index into a list", GitOptions{JsonPath: "spec.components[1]"}, jsonContent, false},
+		{"json key of a list", GitOptions{JsonPath: "spec.components.name"}, jsonContent, true},
+		{"invalid json", GitOptions{JsonPath: "spec"}, yamlContent, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := newContentCheck("https://github.com/org/repo", tt.options)
+			if check.configErr != nil {
+				t.Fatal(check.configErr)
+			}
+			err := check.assertContent("config.yaml", []byte(tt.content))
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if reason := failureReason(err); reason != CONTENT_REASON {
+				t.Errorf("got the %q reason for %v, want %q", reason, err, CONTENT_REASON)
+			}
+		})
+	}
+}
+
+func TestContentPathEmptySegments(t *testing.T) {
+	for _, path := range []string{"spec..name", "..spec", "spec.", "$.spec..components[0]"} {
+		for _, options := range []GitOptions{{YamlPath: path}, {JsonPath: path}} {
+			if check := newContentCheck("https://github.com/org/repo", options); check.configErr == nil {
+				t.Errorf("path %s accepted", path)
+			}
+		}
+	}
+}
+
+func TestCheckContent(t *testing.T) {
+	repo := newTestRepository(t)
+	repo.commit("config.yaml", yamlContent)
+
+	if _, err := newContentCheck(repo.url(), GitOptions{YamlPath: "spec.components[0].name"}).Check(
+		context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	_, err := newContentCheck(repo.url(), GitOptions{YamlPath: "spec.replicas"}).Check(context.Background())
+	if reason := failureReason(err); reason != CONTENT_REASON {
+		t.Errorf("got the %q reason for %v, want %q", reason, err, CONTENT_REASON)
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// STALE_REASON is the failure reason reported when the commit of the revision is older than allowed.
+const STALE_REASON = "stale commit"
+
+// shortSHALength is the length of the SHA exported in the head commit info metric.
+const shortSHALength = 12
+
+// recordHead exports the commit of the checked revision. The commit timestamp is only known when the
+// commit object was fetched, which is not the case in ls-remote mode.
+func (c *GitCheck) recordHead(hash plumbing.Hash, commit *object.Commit) {
+	sha := hash.String()[:shortSHALength]
+	if c.headSHA != sha {
+		if c.headSHA != "" {
+			c.log.Printf("%s: revision moved from %s to %s\n", c.name, c.headSHA, sha)
+			c.gitMetric.HeadInfo.Metric.DeleteLabelValues(c.labelValues(c.headSHA)...)
+			c.gitMetric.HeadChanges.Record(c.labelValues(), 1)
+		}
+		c.headSHA = sha
+	}
+	c.gitMetric.HeadInfo.Record(c.labelValues(sha), 1)
+
+	if commit != nil {
+		c.gitMetric.HeadTimestamp.Record(c.labelValues(), float64(commit.Committer.When.Unix()))
+	}
+}
+
+// checkCommitAge returns an error with the STALE_REASON reason if the commit is older than the
+// configured maximum age.
+func (c *GitCheck) checkCommitAge(commit *object.Commit) error {
+	if c.options.MaxCommitAge == 0 {
+		return nil
+	}
+
+	age := time.Since(commit.Committer.When)
+	if age > c.options.MaxCommitAge {
+		return newCheckError(STALE_REASON, fmt.Errorf("commit %s is %s old, more than %s",
+			commit.Hash.String()[:shortSHALength], age.Round(time.Second), c.options.MaxCommitAge))
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/hacbs-release/release-availability-metrics/pkg/metrics"
+)
+
+// newHeadCheck returns a check of README.md on master failing on commits older than maxCommitAge.
+func newHeadCheck(url string, maxCommitAge time.Duration) *GitCheck {
+	return NewGitCheck("test", "head", "", url, "master", []string{"README.md"},
+		GitOptions{MaxCommitAge: maxCommitAge}, nil, log.New(io.Discard, "", 0),
+		metrics.NewCompositeMetric("test", nil), metrics.NewGitMetric("test", nil))
+}
+
+func TestRecordHeadChange(t *testing.T) {
+	repo := newTestRepository(t)
+	check := newHeadCheck(repo.url(), 0)
+	head, err := repo.r.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := check.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	first := head.Hash().String()[:shortSHALength]
+	if got := testutil.ToFloat64(check.gitMetric.HeadInfo.Metric.WithLabelValues(check.labelValues(first)...)); got != 1 {
+		t.Errorf("head info of %s = %v, want 1", first, got)
+	}
+	if got := testutil.CollectAndCount(check.gitMetric.HeadChanges.Metric); got != 0 {
+		t.Errorf("got %d head change series before any change, want 0", got)
+	}
+
+	second := repo.commit("README.md", "updated").String()[:shortSHALength]
+	if _, err := check.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.CollectAndCount(check.gitMetric.HeadInfo.Metric); got != 1 {
+		t.Errorf("got %d head info series, want only the one of the new head", got)
+	}
+	if got := testutil.ToFloat64(check.gitMetric.HeadInfo.Metric.WithLabelValues(check.labelValues(second)...)); got != 1 {
+		t.Errorf("head info of %s = %v, want 1", second, got)
+	}
+	if got := testutil.ToFloat64(check.gitMetric.HeadChanges.Metric.WithLabelValues(check.labelValues()...)); got != 1 {
+		t.Errorf("head changes = %v, want 1", got)
+	}
+
+	// an unchanged head is not counted again
+	if _, err := check.Check(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(check.gitMetric.HeadChanges.Metric.WithLabelValues(check.labelValues()...)); got != 1 {
+		t.Errorf("head changes = %v after an unchanged run, want 1", got)
+	}
+}
+
+func TestCheckCommitAge(t *testing.T) {
+	commit := &object.Commit{
+		Hash:      plumbing.NewHash("0123456789abcdef0123456789abcdef01234567"),
+		Committer: object.Signature{When: time.Now().Add(-48 * time.Hour)},
+	}
+	tests := []struct {
+		name         string
+		maxCommitAge time.Duration
+		wantReason   string
+	}{
+		{"disabled", 0, ""},
+		{"recent", 72 * time.Hour, ""},
+		{"stale", 24 * time.Hour, STALE_REASON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newHeadCheck("https://github.com/org/repo", tt.maxCommitAge).checkCommitAge(commit)
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if reason := failureReason(err); reason != tt.wantReason {
+				t.Errorf("got the %q reason for %v, want %q", reason, err, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestGitCheckStaleCommit(t *testing.T) {
+	repo := newTestRepository(t)
+	check := newHeadCheck(repo.url(), time.Nanosecond)
+	_, err := check.Check(context.Background())
+	if reason := failureReason(err); reason != STALE_REASON {
+		t.Errorf("got the %q reason for %v, want %q", reason, err, STALE_REASON)
+	}
+}
@@ -0,0 +1,237 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/sideband"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/client"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// openUploadPack opens an upload-pack session to the remote and returns it along with the references
+// advertised by the remote.
+func (c *GitCheck) openUploadPack(ctx context.Context) (transport.UploadPackSession, *packp.AdvRefs, error) {
+	ep, err := transport.NewEndpoint(c.url)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cli, err := client.NewClient(ep)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session, err := cli.NewUploadPackSession(ep, c.getAuth())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	done := traceStep(ctx, "ref advertisement")
+	ar, err := session.AdvertisedReferencesContext(ctx)
+	done(err)
+	if err != nil {
+		session.Close()
+		return nil, nil, err
+	}
+
+	return session, ar, nil
+}
+
+// lsRemote lists the remote references and verifies that the checked revision exists. Objects are only
+// fetched for commits which are not the tip of a reference, and then without their trees. The commit
+// hash of the revision is returned.
+func (c *GitCheck) lsRemote(ctx context.Context) (plumbing.Hash, error) {
+	session, ar, err := c.openUploadPack(ctx)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	defer session.Close()
+
+	rev, err := c.resolveRevision(ar)
+	if err != nil || rev.advertised {
+		return rev.commit, err
+	}
+
+	done := traceStep(ctx, "fetch", attribute.String("sha", rev.hash.String()))
+	storage := memory.NewStorage()
+	err = c.fetchObjects(ctx, session, ar, storage, rev.hash, packp.FilterTreeDepth(0))
+	done(err)
+	if err != nil {
+		return plumbing.ZeroHash, newCheckError(REVISION_REASON, err)
+	}
+	commit, err := getCommit(storage, rev.hash)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return commit.Hash, nil
+}
+
+// fetchCommit fetches the commit of the checked revision and its root tree, leaving the blobs and the
+// other trees out with a tree:1 partial clone filter. Only the trees on the checked paths are fetched
+// afterwards.
+func (c *GitCheck) fetchCommit(ctx context.Context) (*object.Commit, error) {
+	session, ar, err := c.openUploadPack(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	rev, err := c.resolveRevision(ar)
+	if err != nil {
+		return nil, err
+	}
+
+	storage := memory.NewStorage()
+	done := traceStep(ctx, "fetch", attribute.String("sha", rev.hash.String()))
+	err = c.fetchObjects(ctx, session, ar, storage, rev.hash, packp.FilterTreeDepth(1))
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+	commit, err := getCommit(storage, rev.hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return commit, c.fetchPathTrees(ctx, storage, commit)
+}
+
+// fetchPathTrees fetches the trees of the directories leading to the checked paths, each in a new
+// upload-pack session, so that the paths can be looked up in the tree of the commit. The walk of a path
+// stops at the first missing directory, which the lookup then reports.
+func (c *GitCheck) fetchPathTrees(ctx context.Context, storage *memory.Storage, commit *object.Commit) error {
+	root, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range c.paths {
+		tree := root
+		dirs := strings.Split(strings.Trim(path, "/"), "/")
+		for _, dir := range dirs[:len(dirs)-1] {
+			entry, err := tree.FindEntry(dir)
+			if err != nil || entry.Mode != filemode.Dir {
+				break
+			}
+			if _, err := storage.EncodedObject(plumbing.TreeObject, entry.Hash); err != nil {
+				if err := c.fetchTree(ctx, storage, entry.Hash); err != nil {
+					return err
+				}
+			}
+			if tree, err = object.GetTree(storage, entry.Hash); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// fetchTree fetches a single tree without its subtrees and blobs into the storage. Like fetchBlob, it
+// requires the remote to allow wants of any reachable SHA.
+func (c *GitCheck) fetchTree(ctx context.Context, storage *memory.Storage, hash plumbing.Hash) error {
+	session, ar, err := c.openUploadPack(ctx)
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	done := traceStep(ctx, "tree fetch", attribute.String("sha", hash.String()))
+	err = c.fetchObjects(ctx, session, ar, storage, hash, packp.FilterTreeDepth(1))
+	done(err)
+
+	return err
+}
+
+// fetchBlob fetches a single blob in a new upload-pack session. This is how the partial clones fetch the
+// blobs they left out, the remote has to allow wants of any reachable SHA.
+func (c *GitCheck) fetchBlob(ctx context.Context, hash plumbing.Hash) ([]byte, error) {
+	session, ar, err := c.openUploadPack(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	done := traceStep(ctx, "blob fetch", attribute.String("sha", hash.String()))
+	storage := memory.NewStorage()
+	err = c.fetchObjects(ctx, session, ar, storage, hash, "")
+	done(err)
+	if err != nil {
+		return nil, err
+	}
+
+	blob, err := object.GetBlob(storage, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return readBlob(blob)
+}
+
+// fetchObjects fetches the object of the given hash into the storage. Commits are fetched with a depth of
+// 1 and the partial clone filter, if set.
+func (c *GitCheck) fetchObjects(ctx context.Context, session transport.UploadPackSession, ar *packp.AdvRefs,
+	storage *memory.Storage, hash plumbing.Hash, filter packp.Filter) error {
+	req := packp.NewUploadPackRequestFromCapabilities(ar.Capabilities)
+	req.Wants = []plumbing.Hash{hash}
+	if filter != "" {
+		if !ar.Capabilities.Supports(capability.Filter) {
+			return fmt.Errorf("remote does not support partial clone filters")
+		}
+		req.Depth = packp.DepthCommits(1)
+		req.Filter = filter
+		for _, capa := range []capability.Capability{capability.Shallow, capability.Filter} {
+			if err := req.Capabilities.Set(capa); err != nil {
+				return err
+			}
+		}
+	}
+	if ar.Capabilities.Supports(capability.NoProgress) {
+		if err := req.Capabilities.Set(capability.NoProgress); err != nil {
+			return err
+		}
+	}
+
+	resp, err := session.UploadPack(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Close()
+
+	var reader io.Reader = resp
+	switch {
+	case req.Capabilities.Supports(capability.Sideband64k):
+		reader = sideband.NewDemuxer(sideband.Sideband64k, resp)
+	case req.Capabilities.Supports(capability.Sideband):
+		reader = sideband.NewDemuxer(sideband.Sideband, resp)
+	}
+
+	return packfile.UpdateObjectStorage(storage, reader)
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os/exec"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/hacbs-release/release-availability-metrics/pkg/metrics"
+)
+
+// newSparseRepository creates a test repository allowing partial clones and wants of any object, with a
+// file in a nested directory and an unrelated directory next to it.
+func newSparseRepository(t *testing.T) *testRepository {
+	repo := newTestRepository(t)
+	for _, option := range []string{"uploadpack.allowFilter", "uploadpack.allowAnySHA1InWant"} {
+		if out, err := exec.Command("git", "-C", repo.dir, "config", option, "true").CombinedOutput(); err != nil {
+			t.Fatalf("git config %s: %v: %s", option, err, out)
+		}
+	}
+	repo.commit("other/file.txt", "other")
+	repo.commit("dir/sub/file.txt", "content")
+
+	return repo
+}
+
+// newSparseCheck returns a sparse check of the paths on master.
+func newSparseCheck(url string, paths ...string) *GitCheck {
+	return NewGitCheck("test", "sparse", "", url, "master", paths, GitOptions{Mode: GIT_MODE_SPARSE}, nil,
+		log.New(io.Discard, "", 0), metrics.NewCompositeMetric("test", nil), metrics.NewGitMetric("test", nil))
+}
+
+func TestSparseFetchesOnlyPathTrees(t *testing.T) {
+	repo := newSparseRepository(t)
+	check := newSparseCheck(repo.url(), "dir/sub/file.txt")
+
+	commit, err := check.fetchCommit(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := commit.Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := root.FindEntry("dir/sub/file.txt"); err != nil {
+		t.Errorf("tree of the checked path not fetched: %v", err)
+	}
+	// the entry of the unrelated directory is in the root tree, its tree is not fetched
+	if _, err := root.FindEntry("other"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := root.Tree("other"); !errors.Is(err, object.ErrDirectoryNotFound) {
+		t.Errorf("got %v for an unrelated tree, want it not fetched", err)
+	}
+}
+
+func TestSparseCheck(t *testing.T) {
+	repo := newSparseRepository(t)
+	tests := map[string]struct {
+		path    string
+		wantErr bool
+	}{
+		"nested file":       {"dir/sub/file.txt", false},
+		"top level file":    {"README.md", false},
+		"missing file":      {"dir/sub/missing.txt", true},
+		"missing directory": {"missing/file.txt", true},
+		"directory":         {"dir/sub", true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := newSparseCheck(repo.url(), tt.path).Check(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got %v, want an error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPathGauges(t *testing.T) {
+	repo := newSparseRepository(t)
+	check := newSparseCheck(repo.url(), "README.md", "dir/sub/missing.txt", "dir/sub/file.txt")
+	if _, err := check.Check(context.Background()); err == nil {
+		t.Fatal("got no error with a missing path")
+	}
+
+	// the series are collected before gaugeValue creates the missing ones
+	if got := testutil.CollectAndCount(check.gitMetric.Path.Metric); got != 3 {
+		t.Errorf("got %d path series, want 3", got)
+	}
+	if got := testutil.CollectAndCount(check.metric.Up.Metric); got != 1 {
+		t.Errorf("got %d check up series, want 1", got)
+	}
+	want := map[string]float64{"README.md": 1, "dir/sub/missing.txt": 0, "dir/sub/file.txt": 1}
+	for path, value := range want {
+		if got := gaugeValue(t, check.gitMetric.Path, check.labelValues(path)); got != value {
+			t.Errorf("path gauge of %s = %v, want %v", path, got, value)
+		}
+	}
+	if got := gaugeValue(t, check.metric.Up, check.labelValues("false")); got != 0 {
+		t.Errorf("check up = %v, want 0", got)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+)
+
+// REVISION_REASON is the failure reason reported when the revision can't be resolved on the remote.
+const REVISION_REASON = "revision not found"
+
+var commitRe = regexp.MustCompile(`^[0-9a-fA-F]{4,40}$`)
+
+// revision is a revision resolved against the references advertised by the remote.
+type revision struct {
+	// name is the reference name, empty when the revision is a commit
+	name plumbing.ReferenceName
+	// hash is the hash of the reference or the commit
+	hash plumbing.Hash
+	// advertised is true if the hash is the tip of an advertised reference
+	advertised bool
+	// commit is the hash of the commit, which differs from hash for annotated tags
+	commit plumbing.Hash
+}
+
+// resolveRevision resolves the checked revision the way git does: a full reference, then a tag, then a
+// branch, then a commit SHA. The ref type option restricts the resolution to one kind of revision. Short
+// commit SHAs can only be resolved if they are the tip of an advertised reference.
+func (c *GitCheck) resolveRevision(ar *packp.AdvRefs) (revision, error) {
+	rev := c.revision
+
+	var candidates []string
+	switch {
+	case c.options.RefType == GIT_REF_COMMIT:
+	case rev == "" || rev == "HEAD":
+		if ar.Head == nil {
+			return revision{}, newCheckError(REVISION_REASON, fmt.Errorf("remote HEAD not advertised"))
+		}
+		return revision{name: plumbing.HEAD, hash: *ar.Head, advertised: true, commit: *ar.Head}, nil
+	case strings.HasPrefix(rev, "refs/"):
+		candidates = []string{rev}
+	case c.options.RefType == GIT_REF_BRANCH:
+		candidates = []string{"refs/heads/" + rev}
+	case c.options.RefType == GIT_REF_TAG:
+		candidates = []string{"refs/tags/" + rev}
+	default:
+		candidates = []string{"refs/" + rev, "refs/tags/" + rev, "refs/heads/" + rev}
+	}
+
+	for _, name := range candidates {
+		if hash, ok := ar.References[name]; ok {
+			commit, peeled := ar.Peeled[name]
+			if !peeled {
+				commit = hash
+			}
+			return revision{name: plumbing.ReferenceName(name), hash: hash, advertised: true, commit: commit}, nil
+		}
+	}
+
+	if (c.options.RefType == "" || c.options.RefType == GIT_REF_COMMIT) && commitRe.MatchString(rev) {
+		return resolveCommit(ar, strings.ToLower(rev))
+	}
+
+	return revision{}, newCheckError(REVISION_REASON, fmt.Errorf("%s not found on the remote", rev))
+}
+
+// resolveCommit resolves a commit SHA. Full SHAs are returned as is, short SHAs have to match the tip of
+// exactly one advertised reference.
+func resolveCommit(ar *packp.AdvRefs, sha string) (revision, error) {
+	var matches []plumbing.Hash
+	for _, hash := range ar.References {
+		if strings.HasPrefix(hash.String(), sha) && !slices.Contains(matches, hash) {
+			matches = append(matches, hash)
+		}
+	}
+
+	if len(sha) == 40 {
+		hash := plumbing.NewHash(sha)
+		return revision{hash: hash, advertised: len(matches) == 1, commit: hash}, nil
+	}
+	switch len(matches) {
+	case 0:
+		return revision{}, newCheckError(REVISION_REASON,
+			fmt.Errorf("short commit SHA %s is not a remote tip, use the full SHA", sha))
+	case 1:
+		return revision{hash: matches[0], advertised: true, commit: matches[0]}, nil
+	default:
+		return revision{}, newCheckError(REVISION_REASON, fmt.Errorf("short commit SHA %s is ambiguous", sha))
+	}
+}
+
+// getCommit returns the commit of the given hash, peeling annotated tags.
+func getCommit(s storer.EncodedObjectStorer, hash plumbing.Hash) (*object.Commit, error) {
+	obj, err := object.GetObject(s, hash)
+	if err != nil {
+		return nil, err
+	}
+	if tag, ok := obj.(*object.Tag); ok {
+		obj, err = tag.Object()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	commit, ok := obj.(*object.Commit)
+	if !ok {
+		return nil, fmt.Errorf("%s does not point to a commit", hash)
+	}
+
+	return commit, nil
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+
+	"github.com/hacbs-release/release-availability-metrics/pkg/metrics"
+)
+
+// revisionRepository holds a test repository with a branch and a tag both named v1 on different commits,
+// and an annotated tag.
+type revisionRepository struct {
+	*testRepository
+	branch, tag, annotated, tagObject plumbing.Hash
+}
+
+// newRevisionRepository creates a revisionRepository.
+func newRevisionRepository(t *testing.T) *revisionRepository {
+	repo := &revisionRepository{testRepository: newTestRepository(t)}
+	repo.tag = repo.commit("README.md", "tagged")
+	if _, err := repo.r.CreateTag("v1", repo.tag, nil); err != nil {
+		t.Fatal(err)
+	}
+	repo.annotated = repo.commit("README.md", "annotated")
+	tag, err := repo.r.CreateTag("v2", repo.annotated, &git.CreateTagOptions{
+		Tagger:  &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		Message: "release v2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.tagObject = tag.Hash()
+	repo.branch = repo.commit("README.md", "branch")
+	if err := repo.r.Storer.SetReference(plumbing.NewHashReference("refs/heads/v1", repo.branch)); err != nil {
+		t.Fatal(err)
+	}
+
+	return repo
+}
+
+// advertisedRefs returns the references advertised by the repository.
+func (repo *revisionRepository) advertisedRefs() *packp.AdvRefs {
+	session, ar, err := newRevisionCheck(repo.url(), "", "").openUploadPack(context.Background())
+	if err != nil {
+		repo.t.Fatal(err)
+	}
+	session.Close()
+
+	return ar
+}
+
+// newRevisionCheck returns a check of the revision, restricted to the ref type if set.
+func newRevisionCheck(url, rev, refType string) *GitCheck {
+	return NewGitCheck("test", "revision", "", url, rev, []string{"README.md"}, GitOptions{RefType: refType}, nil,
+		log.New(io.Discard, "", 0), metrics.NewCompositeMetric("test", nil), metrics.NewGitMetric("test", nil))
+}
+
+func TestResolveRevision(t *testing.T) {
+	repo := newRevisionRepository(t)
+	ar := repo.advertisedRefs()
+
+	tests := []struct {
+		name       string
+		rev        string
+		refType    string
+		wantRef    plumbing.ReferenceName
+		wantHash   plumbing.Hash
+		wantCommit plumbing.Hash
+	}{
+		{"tag before branch", "v1", "", "refs/tags/v1", repo.tag, repo.tag},
+		{"full reference", "refs/heads/v1", "", "refs/heads/v1", repo.branch, repo.branch},
+		{"heads prefix", "heads/v1", "", "refs/heads/v1", repo.branch, repo.branch},
+		{"forced branch", "v1", GIT_REF_BRANCH, "refs/heads/v1", repo.branch, repo.branch},
+		{"forced tag", "v1", GIT_REF_TAG, "refs/tags/v1", repo.tag, repo.tag},
+		{"peeled annotated tag", "v2", "", "refs/tags/v2", repo.tagObject, repo.annotated},
+		{"branch", "master", "", "refs/heads/master", repo.branch, repo.branch},
+		{"full SHA", repo.tag.String(), "", "", repo.tag, repo.tag},
+		{"short SHA of a tip", repo.branch.String()[:8], "", "", repo.branch, repo.branch},
+		{"forced commit", repo.branch.String(), GIT_REF_COMMIT, "", repo.branch, repo.branch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rev, err := newRevisionCheck(repo.url(), tt.rev, tt.refType).resolveRevision(ar)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rev.name != tt.wantRef || rev.hash != tt.wantHash || rev.commit != tt.wantCommit {
+				t.Errorf("resolved %s to %s %s (commit %s), want %s %s (commit %s)", tt.rev, rev.name, rev.hash,
+					rev.commit, tt.wantRef, tt.wantHash, tt.wantCommit)
+			}
+		})
+	}
+}
+
+func TestResolveRevisionNotFound(t *testing.T) {
+	repo := newRevisionRepository(t)
+	ar := repo.advertisedRefs()
+
+	tests := []struct {
+		name    string
+		rev     string
+		refType string
+	}{
+		{"unknown reference", "v3", ""},
+		{"tag forced as branch", "v2", GIT_REF_BRANCH},
+		{"branch forced as tag", "master", GIT_REF_TAG},
+		{"short SHA not a tip", repo.annotated.String()[:8], ""},
+		{"SHA forced as branch", repo.branch.String(), GIT_REF_BRANCH},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newRevisionCheck(repo.url(), tt.rev, tt.refType).resolveRevision(ar)
+			if reason := failureReason(err); reason != REVISION_REASON {
+				t.Errorf("got the %q reason for %v, want %q", reason, err, REVISION_REASON)
+			}
+		})
+	}
+}
+
+func TestResolveCommitAmbiguous(t *testing.T) {
+	ar := packp.NewAdvRefs()
+	ar.References["refs/heads/a"] = plumbing.NewHash("abcd1" + strings.Repeat("0", 35))
+	ar.References["refs/heads/b"] = plumbing.NewHash("abcd2" + strings.Repeat("0", 35))
+
+	_, err := resolveCommit(ar, "abcd")
+	if reason := failureReason(err); reason != REVISION_REASON {
+		t.Errorf("got the %q reason for %v, want %q", reason, err, REVISION_REASON)
+	}
+	rev, err := resolveCommit(ar, "abcd1")
+	if err != nil || rev.hash != ar.References["refs/heads/a"] {
+		t.Errorf("resolved abcd1 to %s, %v, want the tip of refs/heads/a", rev.hash, err)
+	}
+}
+
+func TestCheckAnnotatedTag(t *testing.T) {
+	repo := newRevisionRepository(t)
+	if _, err := newRevisionCheck(repo.url(), "v2", "").Check(context.Background()); err != nil {
+		t.Errorf("annotated tag not peeled: %v", err)
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// UNSIGNED_REASON is the failure reason when the commit of the revision is not signed.
+	UNSIGNED_REASON = "unsigned commit"
+	// UNTRUSTED_REASON is the failure reason when the commit signature is invalid or made by an unknown key.
+	UNTRUSTED_REASON = "untrusted signature"
+
+	pgpKeyHeader       = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+	sshSignatureHeader = "-----BEGIN SSH SIGNATURE-----"
+	// sshSigMagic is the preamble of the SSHSIG signature format.
+	sshSigMagic = "SSHSIG"
+	// sshSigNamespace is the namespace git signs commits in.
+	sshSigNamespace = "git"
+)
+
+// trustedKeys holds the keys commit signatures are verified against.
+type trustedKeys struct {
+	pgp []string
+	ssh []ssh.PublicKey
+}
+
+// sshSignature is the SSHSIG signature blob as produced by ssh-keygen -Y sign.
+type sshSignature struct {
+	Magic         [6]byte
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      []byte
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshSignedData is the data signed in the SSHSIG format.
+type sshSignedData struct {
+	Magic         [6]byte
+	Namespace     string
+	Reserved      []byte
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// loadTrustedKeys parses the trusted keys. Each value is an armored PGP public key block, ssh public
+// keys in the authorized_keys format, or the path to a file holding either.
+func loadTrustedKeys(values []string) (trustedKeys, error) {
+	keys := trustedKeys{}
+	for _, value := range values {
+		data := []byte(value)
+		if !strings.Contains(value, pgpKeyHeader) && !isSSHPublicKey(value) {
+			var err error
+			data, err = os.ReadFile(value)
+			if err != nil {
+				return keys, fmt.Errorf("failed to read trusted key: %v", err)
+			}
+		}
+
+		if bytes.Contains(data, []byte(pgpKeyHeader)) {
+			keys.pgp = append(keys.pgp, string(data))
+			continue
+		}
+		for len(bytes.TrimSpace(data)) != 0 {
+			key, _, _, rest, err := ssh.ParseAuthorizedKey(data)
+			if err != nil {
+				return keys, fmt.Errorf("invalid trusted key: %v", err)
+			}
+			keys.ssh = append(keys.ssh, key)
+			data = rest
+		}
+	}
+
+	return keys, nil
+}
+
+// isSSHPublicKey tells whether value looks like a public key in the authorized_keys format.
+func isSSHPublicKey(value string) bool {
+	for _, prefix := range []string{"ssh-", "ecdsa-", "sk-"} {
+		if strings.HasPrefix(strings.TrimSpace(value), prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifySignature checks that the commit is signed by one of the trusted keys.
+func (c *GitCheck) verifySignature(commit *object.Commit) error {
+	if commit.PGPSignature == "" {
+		return newCheckError(UNSIGNED_REASON, fmt.Errorf("commit %s is not signed", commit.Hash))
+	}
+
+	var err error
+	if strings.HasPrefix(commit.PGPSignature, sshSignatureHeader) {
+		err = c.verifySSHSignature(commit)
+	} else {
+		err = c.verifyPGPSignature(commit)
+	}
+	if err != nil {
+		return newCheckError(UNTRUSTED_REASON, fmt.Errorf("commit %s: %v", commit.Hash, err))
+	}
+
+	return nil
+}
+
+// verifyPGPSignature verifies the GPG signature of the commit against the trusted PGP keys.
+func (c *GitCheck) verifyPGPSignature(commit *object.Commit) error {
+	if len(c.trustedKeys.pgp) == 0 {
+		return fmt.Errorf("no trusted gpg key")
+	}
+
+	var err error
+	for _, keyRing := range c.trustedKeys.pgp {
+		if _, err = commit.Verify(keyRing); err == nil {
+			return nil
+		}
+	}
+
+	return err
+}
+
+// verifySSHSignature verifies the SSH signature of the commit against the trusted ssh keys.
+func (c *GitCheck) verifySSHSignature(commit *object.Commit) error {
+	block, _ := pem.Decode([]byte(commit.PGPSignature))
+	if block == nil {
+		return fmt.Errorf("malformed ssh signature")
+	}
+	var sig sshSignature
+	if err := ssh.Unmarshal(block.Bytes, &sig); err != nil {
+		return fmt.Errorf("malformed ssh signature: %v", err)
+	}
+	if string(sig.Magic[:]) != sshSigMagic || sig.Version != 1 {
+		return fmt.Errorf("unsupported ssh signature")
+	}
+	if sig.Namespace != sshSigNamespace {
+		return fmt.Errorf("ssh signature namespace is %q", sig.Namespace)
+	}
+
+	key, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return err
+	}
+	trusted := false
+	for _, trustedKey := range c.trustedKeys.ssh {
+		if bytes.Equal(trustedKey.Marshal(), key.Marshal()) {
+			trusted = true
+			break
+		}
+	}
+	if !trusted {
+		return fmt.Errorf("signed by unknown key %s", ssh.FingerprintSHA256(key))
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported ssh signature hash %s", sig.HashAlgorithm)
+	}
+	if err := encodeWithoutSignature(commit, h); err != nil {
+		return err
+	}
+
+	var signature ssh.Signature
+	if err := ssh.Unmarshal(sig.Signature, &signature); err != nil {
+		return fmt.Errorf("malformed ssh signature: %v", err)
+	}
+	signed := ssh.Marshal(sshSignedData{
+		Magic:         sig.Magic,
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	})
+
+	return key.Verify(signed, &signature)
+}
+
+// encodeWithoutSignature writes the commit object, as it was signed, to w.
+func encodeWithoutSignature(commit *object.Commit, w io.Writer) error {
+	encoded := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(encoded); err != nil {
+		return err
+	}
+	r, err := encoded.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	_, err = io.Copy(w, r)
+
+	return err
+}
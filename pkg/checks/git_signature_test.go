@@ -0,0 +1,142 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// The fixtures of testdata/signature were made with
+//
+//	ssh-keygen -Y sign -n git [-O hashalg=sha256] -f key < commit.txt
+//	gpg --armor --detach-sign commit.txt
+//
+// commit.txt being the commit object as git signs it, without its gpgsig header.
+
+// readFixture returns the content of a signature fixture.
+func readFixture(t *testing.T, name string) string {
+	data, err := os.ReadFile(filepath.Join("testdata", "signature", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(data)
+}
+
+// signedCommit decodes the fixture commit with the signature of the given fixture in its gpgsig header,
+// the way git stores signed commits.
+func signedCommit(t *testing.T, signatureFixture string) *object.Commit {
+	payload := readFixture(t, "commit.txt")
+	headers, message, _ := strings.Cut(payload, "\n\n")
+
+	raw := headers + "\n"
+	if signatureFixture != "" {
+		lines := strings.Split(strings.TrimSuffix(readFixture(t, signatureFixture), "\n"), "\n")
+		raw += "gpgsig " + strings.Join(lines, "\n ") + "\n"
+	}
+	raw += "\n" + message
+
+	encoded := &plumbing.MemoryObject{}
+	encoded.SetType(plumbing.CommitObject)
+	if _, err := encoded.Write([]byte(raw)); err != nil {
+		t.Fatal(err)
+	}
+	commit := &object.Commit{}
+	if err := commit.Decode(encoded); err != nil {
+		t.Fatal(err)
+	}
+
+	return commit
+}
+
+func TestVerifySignature(t *testing.T) {
+	tests := []struct {
+		name       string
+		signature  string
+		trusted    []string
+		tamper     bool
+		wantReason string
+	}{
+		{"ssh sha512", "commit_sha512.sig", []string{"trusted_key.pub"}, false, ""},
+		{"ssh sha256", "commit_sha256.sig", []string{"trusted_key.pub"}, false, ""},
+		{"ssh among several keys", "commit_sha512.sig", []string{"untrusted_key.pub", "trusted_key.pub"}, false, ""},
+		{"ssh wrong namespace", "commit_file_namespace.sig", []string{"trusted_key.pub"}, false, UNTRUSTED_REASON},
+		{"ssh untrusted key", "commit_sha512.sig", []string{"untrusted_key.pub"}, false, UNTRUSTED_REASON},
+		{"ssh tampered commit", "commit_sha256.sig", []string{"trusted_key.pub"}, true, UNTRUSTED_REASON},
+		{"gpg", "commit.asc", []string{"trusted_key.asc"}, false, ""},
+		{"gpg untrusted key", "commit.asc", []string{"trusted_key.pub"}, false, UNTRUSTED_REASON},
+		{"gpg tampered commit", "commit.asc", []string{"trusted_key.asc"}, true, UNTRUSTED_REASON},
+		{"unsigned", "", []string{"trusted_key.pub"}, false, UNSIGNED_REASON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := []string{}
+			for _, name := range tt.trusted {
+				values = append(values, readFixture(t, name))
+			}
+			keys, err := loadTrustedKeys(values)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			commit := signedCommit(t, tt.signature)
+			if tt.tamper {
+				commit.Message = "tampered commit\n"
+			}
+			err = (&GitCheck{trustedKeys: keys}).verifySignature(commit)
+			switch {
+			case tt.wantReason == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantReason != "" && err == nil:
+				t.Errorf("expected the verification to fail with %q", tt.wantReason)
+			case tt.wantReason != "" && failureReason(err) != tt.wantReason:
+				t.Errorf("reason = %q, want %q", failureReason(err), tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestLoadTrustedKeysFromFile(t *testing.T) {
+	keys, err := loadTrustedKeys([]string{
+		filepath.Join("testdata", "signature", "trusted_key.pub"),
+		filepath.Join("testdata", "signature", "trusted_key.asc"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys.ssh) != 1 || len(keys.pgp) != 1 {
+		t.Errorf("loaded %d ssh and %d gpg keys, want one of each", len(keys.ssh), len(keys.pgp))
+	}
+}
+
+func TestEncodeWithoutSignature(t *testing.T) {
+	for _, signature := range []string{"commit_sha512.sig", "commit.asc"} {
+		var encoded bytes.Buffer
+		if err := encodeWithoutSignature(signedCommit(t, signature), &encoded); err != nil {
+			t.Fatal(err)
+		}
+		if want := readFixture(t, "commit.txt"); encoded.String() != want {
+			t.Errorf("%s: encoded\n%q\nwant the signed payload\n%q", signature, encoded.String(), want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// HOST_KEY_REASON is the failure reason reported when the remote host key doesn't match the known hosts.
+	HOST_KEY_REASON = "host key mismatch"
+	// UNKNOWN_HOST_REASON is the failure reason reported when the remote host is missing from the known
+	// hosts.
+	UNKNOWN_HOST_REASON = "unknown host"
+)
+
+// isSSHUrl returns true for the urls handled by the ssh transport: ssh:// urls and scp-like urls, like
+// user@host:org/repo.git, whatever the user.
+func isSSHUrl(url string) bool {
+	ep, err := transport.NewEndpoint(url)
+
+	return err == nil && ep.Protocol == "ssh"
+}
+
+// newSSHAuth returns the ssh auth method of the check. The key can be given as data or as the path to a
+// file. The host keys are verified against the known hosts data and the known hosts file, or against the
+// default known hosts files if both are empty.
+func (c *GitCheck) newSSHAuth(key, passphrase, knownHosts, knownHostsFile string) (transport.AuthMethod,
+	error) {
+	if key == "" {
+		return nil, fmt.Errorf("ssh url %s requires an ssh key", c.url)
+	}
+
+	ep, err := transport.NewEndpoint(c.url)
+	if err != nil {
+		return nil, err
+	}
+	user := ep.User
+	if user == "" {
+		user = "git"
+	}
+
+	pemBytes, err := loadPEM(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ssh key: %v", err)
+	}
+	auth, err := gitssh.NewPublicKeys(user, pemBytes, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	if knownHostsFile != "" {
+		files = append(files, knownHostsFile)
+	}
+	if knownHosts != "" {
+		file, err := writeKnownHosts(knownHosts)
+		if err != nil {
+			return nil, err
+		}
+		// the database reads the files once, when created
+		defer os.Remove(file)
+		files = append(files, file)
+	}
+	db, err := gitssh.NewKnownHostsDb(files...)
+	if err != nil {
+		return nil, err
+	}
+
+	// the host key errors are recorded, as the ssh transport doesn't keep them in the returned error
+	callback := db.HostKeyCallback()
+	auth.HostKeyCallback = func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) {
+			c.hostKeyErr = err
+		}
+		return err
+	}
+	port := ep.Port
+	if port == 0 {
+		port = 22
+	}
+	auth.HostKeyAlgorithms = db.HostKeyAlgorithms(net.JoinHostPort(ep.Host, fmt.Sprint(port)))
+
+	return auth, nil
+}
+
+// writeKnownHosts writes the known hosts data to a temporary file, as the known hosts database is file
+// based, and returns its path. The caller removes the file.
+func writeKnownHosts(knownHosts string) (string, error) {
+	file, err := os.CreateTemp("", "known_hosts-")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(knownHosts); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	return file.Name(), nil
+}
+
+// classifyError returns err as a CheckError if the ssh host key verification failed during the run, with
+// the UNKNOWN_HOST_REASON reason if the host is missing from the known hosts, HOST_KEY_REASON otherwise.
+func (c *GitCheck) classifyError(err error) error {
+	var keyErr *knownhosts.KeyError
+	if !errors.As(c.hostKeyErr, &keyErr) {
+		return err
+	}
+	if len(keyErr.Want) == 0 {
+		return newCheckError(UNKNOWN_HOST_REASON, c.hostKeyErr)
+	}
+
+	return newCheckError(HOST_KEY_REASON, c.hostKeyErr)
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/hacbs-release/release-availability-metrics/pkg/metrics"
+)
+
+func TestIsSSHUrl(t *testing.T) {
+	tests := map[string]bool{
+		"ssh://git@github.com/org/repo.git":  true,
+		"ssh://github.com:2222/org/repo.git": true,
+		"git@github.com:org/repo.git":        true,
+		"deploy@gitlab.example.com:org/repo": true,
+		"gitlab.example.com:org/repo.git":    true,
+		"https://github.com/org/repo.git":    false,
+		"https://git@github.com/org/repo":    false,
+		"file:///srv/git/repo.git":           false,
+		"/srv/git/repo.git":                  false,
+	}
+	for url, want := range tests {
+		if got := isSSHUrl(url); got != want {
+			t.Errorf("isSSHUrl(%q) = %v, want %v", url, got, want)
+		}
+	}
+}
+
+// newHostKey returns a new ssh host key.
+func newHostKey(t *testing.T) ssh.PublicKey {
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+// newSSHKey returns a new PEM encoded ssh private key.
+func newSSHKey(t *testing.T) string {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(block))
+}
+
+// newSSHCheck returns a check of an scp-like url on host, knowing the host key of known.
+func newSSHCheck(t *testing.T, known ssh.PublicKey) *GitCheck {
+	check := NewGitCheck("test", "ssh", "", "deploy@git.example.com:org/repo.git", "main", []string{"README.md"},
+		GitOptions{
+			SSHKey:     newSSHKey(t),
+			KnownHosts: knownhosts.Line([]string{"git.example.com"}, known),
+		}, nil, log.New(io.Discard, "", 0), metrics.NewCompositeMetric("test", nil),
+		metrics.NewGitMetric("test", nil))
+	if check.configErr != nil {
+		t.Fatal(check.configErr)
+	}
+
+	return check
+}
+
+func TestSSHAuthUser(t *testing.T) {
+	check := newSSHCheck(t, newHostKey(t))
+	auth, ok := check.auth.(*gitssh.PublicKeys)
+	if !ok {
+		t.Fatalf("got the %T auth method for an scp-like url, want ssh public keys", check.auth)
+	}
+	if auth.User != "deploy" {
+		t.Errorf("got the %s user, want the deploy user of the url", auth.User)
+	}
+}
+
+func TestSSHHostKeyReasons(t *testing.T) {
+	known := newHostKey(t)
+	remote := &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22}
+	tests := []struct {
+		name       string
+		hostname   string
+		key        ssh.PublicKey
+		wantReason string
+	}{
+		{"known host", "git.example.com:22", known, ""},
+		{"mismatching key", "git.example.com:22", newHostKey(t), HOST_KEY_REASON},
+		{"unknown host", "other.example.com:22", known, UNKNOWN_HOST_REASON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check := newSSHCheck(t, known)
+			auth := check.auth.(*gitssh.PublicKeys)
+			callbackErr := auth.HostKeyCallback(tt.hostname, remote, tt.key)
+			if tt.wantReason == "" {
+				if callbackErr != nil {
+					t.Errorf("unexpected error: %v", callbackErr)
+				}
+				return
+			}
+
+			err := check.classifyError(errors.New("handshake failed"))
+			if reason := failureReason(err); reason != tt.wantReason {
+				t.Errorf("got the %q reason for %v, want %q", reason, err, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestKnownHostsDataNotLeftOnDisk(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	check := newSSHCheck(t, newHostKey(t))
+
+	files, err := os.ReadDir(tmp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 0 {
+		t.Errorf("got the %v files left in the temporary directory, want none", files)
+	}
+	// the known hosts are still verified once the file is removed
+	auth := check.auth.(*gitssh.PublicKeys)
+	if err := auth.HostKeyCallback("other.example.com:22", &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22},
+		newHostKey(t)); err == nil {
+		t.Error("got no error for an unknown host")
+	}
+}
+
+func TestKnownHostsFile(t *testing.T) {
+	known := newHostKey(t)
+	// a path with a space is a path, not known hosts data
+	path := filepath.Join(t.TempDir(), "known hosts")
+	if err := os.WriteFile(path, []byte(knownhosts.Line([]string{"git.example.com"}, known)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	check := NewGitCheck("test", "ssh", "", "deploy@git.example.com:org/repo.git", "main", []string{"README.md"},
+		GitOptions{SSHKey: newSSHKey(t), KnownHostsFile: path}, nil, log.New(io.Discard, "", 0),
+		metrics.NewCompositeMetric("test", nil), metrics.NewGitMetric("test", nil))
+	if check.configErr != nil {
+		t.Fatal(check.configErr)
+	}
+	auth := check.auth.(*gitssh.PublicKeys)
+	if err := auth.HostKeyCallback("git.example.com:22", &net.TCPAddr{IP: net.IPv4(192, 0, 2, 1), Port: 22},
+		known); err != nil {
+		t.Errorf("unexpected error for a host of the known hosts file: %v", err)
+	}
+}
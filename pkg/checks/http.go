@@ -22,14 +22,16 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	neturl "net/url"
 	"regexp"
+	"strings"
 
 	"github.com/hacbs-release/release-availability-metrics/pkg/metrics"
 )
 
 // defines the HttpCheck type.
 type HttpCheck struct {
-	name     string
+	checkBase
 	username string
 	password string
 	url      string
@@ -40,14 +42,12 @@ type HttpCheck struct {
 	scheme   string
 	host     string
 	path     string
-	log      *log.Logger
-	metric   metrics.CompositeMetric
 	client   *http.Client
 }
 
 // NewHttpCheck returns a new instance of HttpCheck.
-func NewHttpCheck(name, username, password, url, cert, key string, insecure, follow bool, log *log.Logger,
-	metric metrics.CompositeMetric,
+func NewHttpCheck(name, username, password, url, cert, key string, insecure, follow bool,
+	labels map[string]string, log *log.Logger, metric metrics.CompositeMetric,
 ) *HttpCheck {
 	clientTLSCert, _ := tls.X509KeyPair([]byte(cert), []byte(key))
 	tr := &http.Transport{
@@ -58,16 +58,14 @@ func NewHttpCheck(name, username, password, url, cert, key string, insecure, fol
 	}
 
 	newCheck := &HttpCheck{
-		name:     name,
-		username: username,
-		password: password,
-		url:      url,
-		cert:     cert,
-		key:      key,
-		insecure: insecure,
-		follow:   follow,
-		log:      log,
-		metric:   metric,
+		checkBase: checkBase{name: name, log: log, metric: metric},
+		username:  username,
+		password:  password,
+		url:       url,
+		cert:      cert,
+		key:       key,
+		insecure:  insecure,
+		follow:    follow,
 		client: &http.Client{
 			Transport: tr,
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
@@ -80,6 +78,12 @@ func NewHttpCheck(name, username, password, url, cert, key string, insecure, fol
 	}
 	newCheck.parseUrl()
 
+	var target string
+	if u, err := neturl.Parse(url); err == nil {
+		target = strings.ToLower(u.Hostname())
+	}
+	newCheck.labels = metric.LabelValues(name, CHECK_TYPE_HTTP, target, labels)
+
 	return newCheck
 }
 
@@ -100,7 +104,7 @@ func (c *HttpCheck) checkUrl(ctx context.Context) (CheckResult, error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", c.url, nil)
 	if err != nil {
 		c.log.Println(fmt.Sprintf("%s check failed (%s)", c.name, err.Error()))
-		return CheckResult{1, "Failed", err.Error()}, err
+		return CheckResult{1, "Failed", failureReason(err)}, err
 	}
 	if c.username != "" && c.password != "" {
 		data := []byte(fmt.Sprintf("%s:%s", c.username, c.password))
@@ -108,16 +112,19 @@ func (c *HttpCheck) checkUrl(ctx context.Context) (CheckResult, error) {
 		base64.StdEncoding.Encode(encodedCredentials, data)
 		req.Header.Add("Authorization", fmt.Sprintf("Basic %s", encodedCredentials))
 	}
+	done := traceStep(ctx, "request")
 	resp, err := c.client.Do(req)
+	done(err)
 	if err != nil {
 		c.log.Println(fmt.Sprintf("%s check failed (%s)", c.name, err.Error()))
-		return CheckResult{1, "Failed", err.Error()}, err
+		return CheckResult{1, "Failed", failureReason(err)}, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
 		c.log.Println(fmt.Sprintf("%s check failed (%s)", c.name, resp.Status))
-		return CheckResult{1, "Failed", resp.Status}, err
+		err := statusErrorf(resp.StatusCode, "%s", resp.Status)
+		return CheckResult{1, "Failed", failureReason(err)}, err
 	}
 
 	c.log.Println(c.name, "check succeeded")
@@ -125,18 +132,18 @@ func (c *HttpCheck) checkUrl(ctx context.Context) (CheckResult, error) {
 	return CheckResult{0, "Succeeded", ""}, nil
 }
 
-// Check runs a check and returns a float64 of the check result. The float64 is required to push values
-// to prometheus.
-func (c *HttpCheck) Check(ctx context.Context) float64 {
+// Check runs a check and returns a float64 of the check result, along with the error of a failed run. The
+// float64 is required to push values to prometheus.
+func (c *HttpCheck) Check(ctx context.Context) (float64, error) {
 	var reason string
 
 	c.log.Println("running HTTP check:", c.name)
 	res, err := c.checkUrl(ctx)
 	if err != nil {
-		reason = err.Error()
+		reason = failureReason(err)
 	}
-	c.metric.Gauge.Record([]string{c.name}, metrics.FlipValue(res.code))
-	c.metric.Histogram.Record([]string{c.name, reason, res.status}, 1)
+	c.metric.Record(c.labels, res.code, reason)
+	traceResult(ctx, res.code, reason)
 
-	return res.code
+	return res.code, err
 }
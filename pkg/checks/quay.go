@@ -25,64 +25,132 @@ import (
 	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+
 	"github.com/hacbs-release/release-availability-metrics/pkg/metrics"
 )
 
 // QuayCheck sets the necessary parameters to run a check to a container registry.
 type QuayCheck struct {
-	auth   QuayAuth
-	name   string
-	image  string
-	tags   []string
-	log    *log.Logger
-	metric metrics.CompositeMetric
-	client *http.Client
+	checkBase
+	auth      QuayAuth
+	image     string
+	ref       imageReference
+	tags      []string
+	options   QuayOptions
+	configErr error
+	token     string
+	client    *http.Client
+}
+
+// QuayOptions holds the optional settings of a QuayCheck.
+type QuayOptions struct {
+	// PullLayers also pulls the config and layer blobs of each checked tag.
+	PullLayers bool
+	// Insecure skips the verification of the registry TLS certificate.
+	Insecure bool
+	// PlainHttp talks to the registry over http instead of https.
+	PlainHttp bool
+	// CaBundle is a PEM encoded CA bundle, or the path to one, trusted in addition to the system roots.
+	CaBundle string
+	// Signatures requires a cosign signature for each checked tag.
+	Signatures bool
+	// Attestations requires a cosign attestation, like the SLSA provenance, for each checked tag.
+	Attestations bool
+	// Referrers looks signatures and attestations up with the OCI referrers API instead of the cosign
+	// tag scheme. The tag scheme is still used when the registry doesn't support the referrers API.
+	Referrers bool
 }
 
-// NewQuayCheck creates a new QuayCheck instance.
+// NewQuayCheck creates a new QuayCheck instance. The tag or digest set in the image pull spec is
+// checked along with the given tags.
 func NewQuayCheck(
 	auth *QuayAuth,
 	name, image string,
 	tags []string,
+	options QuayOptions,
+	labels map[string]string,
 	log *log.Logger,
 	metric metrics.CompositeMetric,
 ) *QuayCheck {
 	log.Println("creating new Quay check")
+	ref, err := parseImageReference(image)
+	if err != nil {
+		log.Printf("[ERROR] %s: %v\n", name, err)
+	}
+
+	tlsConfig, tlsErr := newTLSConfig(options.Insecure, options.CaBundle)
+	if tlsErr != nil {
+		log.Printf("[ERROR] %s: %v\n", name, tlsErr)
+		if err == nil {
+			err = tlsErr
+		}
+	}
+	// keep the proxy, HTTP/2 and idle connection settings of the default transport
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+
 	return &QuayCheck{
-		auth:   *auth,
-		name:   name,
-		image:  image,
-		tags:   tags,
-		log:    log,
-		metric: metric,
+		checkBase: checkBase{name: name, labels: metric.LabelValues(name, CHECK_TYPE_QUAY, ref.domain, labels),
+			log: log, metric: metric},
+		auth:      *auth,
+		image:     image,
+		ref:       ref,
+		tags:      mergeTags(tags, ref.references()),
+		options:   options,
+		configErr: err,
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= 10 {
+					return fmt.Errorf("stopped after 10 redirects")
+				}
+				// storage backends reject the registry credentials along with their signed urls
+				if req.URL.Host != via[0].URL.Host {
+					req.Header.Del("Authorization")
+				}
+				return nil
+			},
 		},
 	}
 }
 
-// parseImageRef parses an image reference into registry and repository.
-// Example: quay.io/konflux-ci/release-service-utils -> registry=quay.io, repo=konflux-ci/release-service-utils
-func (c *QuayCheck) parseImageRef() (registry, repo string) {
-	parts := strings.SplitN(c.image, "/", 2)
-	if len(parts) == 2 && (strings.Contains(parts[0], ".") || strings.Contains(parts[0], ":")) {
-		registry = parts[0]
-		repo = parts[1]
-	} else {
-		// Default to docker.io for images without explicit registry
-		registry = "docker.io"
-		repo = c.image
-	}
-	return registry, repo
+// mergeTags returns the given tags followed by the image references not already in the list. An empty
+// tag stands for latest, which is also checked when no tag is given at all.
+func mergeTags(tags, references []string) []string {
+	merged := []string{}
+	seen := map[string]bool{}
+	for _, tag := range append(append([]string{}, tags...), references...) {
+		if tag == "" {
+			tag = "latest"
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			merged = append(merged, tag)
+		}
+	}
+	if len(merged) == 0 {
+		merged = append(merged, "latest")
+	}
+
+	return merged
 }
 
 var (
 	realmRe   = regexp.MustCompile(`realm="([^"]+)"`)
 	serviceRe = regexp.MustCompile(`service="([^"]+)"`)
+
+	manifestAcceptHeader = strings.Join([]string{
+		"application/vnd.docker.distribution.manifest.v2+json",
+		"application/vnd.docker.distribution.manifest.list.v2+json",
+		"application/vnd.oci.image.manifest.v1+json",
+		"application/vnd.oci.image.index.v1+json",
+	}, ", ")
 )
 
 // getAuthToken retrieves a bearer token for the registry using the WWW-Authenticate challenge.
-func (c *QuayCheck) getAuthToken(ctx context.Context, registry, repo, wwwAuth string) (string, error) {
+func (c *QuayCheck) getAuthToken(ctx context.Context, repo, wwwAuth string) (string, error) {
 	// Parse WWW-Authenticate header
 	// Example: Bearer realm="https://quay.io/v2/auth",service="quay.io",scope="repository:user/repo:pull"
 	realmMatch := realmRe.FindStringSubmatch(wwwAuth)
@@ -107,8 +175,12 @@ func (c *QuayCheck) getAuthToken(ctx context.Context, registry, repo, wwwAuth st
 	}
 
 	// Add basic auth if credentials provided
-	if c.auth.getUsername() != "" && c.auth.getPassword() != "" {
-		req.SetBasicAuth(c.auth.getUsername(), c.auth.getPassword())
+	username, password, err := c.auth.getCredentials(c.ref.domain, repo)
+	if err != nil {
+		return "", err
+	}
+	if username != "" && password != "" {
+		req.SetBasicAuth(username, password)
 	}
 
 	resp, err := c.client.Do(req)
@@ -119,9 +191,9 @@ func (c *QuayCheck) getAuthToken(ctx context.Context, registry, repo, wwwAuth st
 
 	if resp.StatusCode != http.StatusOK {
 		if resp.StatusCode == http.StatusUnauthorized {
-			return "", fmt.Errorf("authentication failed (status %d) - check credentials", resp.StatusCode)
+			return "", statusErrorf(resp.StatusCode, "authentication failed (status %d) - check credentials", resp.StatusCode)
 		}
-		return "", fmt.Errorf("token request failed with status: %d", resp.StatusCode)
+		return "", statusErrorf(resp.StatusCode, "token request failed with status: %d", resp.StatusCode)
 	}
 
 	var tokenResp struct {
@@ -138,58 +210,80 @@ func (c *QuayCheck) getAuthToken(ctx context.Context, registry, repo, wwwAuth st
 	return tokenResp.AccessToken, nil
 }
 
-// checkManifest checks if a manifest exists for the given image and tag using the registry API.
-func (c *QuayCheck) checkManifest(ctx context.Context, tag string) error {
-	registry, repo := c.parseImageRef()
-	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", registry, repo, tag)
+// checkManifest checks if a manifest exists for the given image and tag using the registry API. The
+// manifest digest is returned if the registry sent it.
+func (c *QuayCheck) checkManifest(ctx context.Context, tag string) (string, error) {
+	resp, err := c.doRegistryRequest(ctx, "HEAD", c.repositoryURL("manifests", tag), accept(manifestAcceptHeader))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
 
-	acceptHeader := strings.Join([]string{
-		"application/vnd.docker.distribution.manifest.v2+json",
-		"application/vnd.docker.distribution.manifest.list.v2+json",
-		"application/vnd.oci.image.manifest.v1+json",
-		"application/vnd.oci.image.index.v1+json",
-	}, ", ")
+	if resp.StatusCode != http.StatusOK {
+		return "", statusErrorf(resp.StatusCode, "manifest check failed with status: %d", resp.StatusCode)
+	}
+
+	return resp.Header.Get("Docker-Content-Digest"), nil
+}
 
-	resp, err := c.doManifestRequest(ctx, manifestURL, acceptHeader, "")
+// repositoryURL returns the registry API url of a manifest or blob of the checked repository.
+func (c *QuayCheck) repositoryURL(kind, reference string) string {
+	scheme := "https"
+	if c.options.PlainHttp {
+		scheme = "http"
+	}
+
+	return fmt.Sprintf("%s://%s/v2/%s/%s/%s", scheme, c.ref.host(), c.ref.repository, kind, reference)
+}
+
+// accept returns the request header accepting the media types.
+func accept(mediaTypes string) http.Header {
+	return http.Header{"Accept": []string{mediaTypes}}
+}
+
+// doRegistryRequest performs a request to a registry URL with the header. If the registry answers with an
+// authentication challenge, a bearer token is requested and the request is retried with it. The token is
+// kept for the following requests of the same check run.
+func (c *QuayCheck) doRegistryRequest(ctx context.Context, method, url string, header http.Header) (*http.Response,
+	error) {
+	resp, err := c.doRequest(ctx, method, url, header, c.token)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer resp.Body.Close()
 
 	// If unauthorized, try to get a token and retry
 	if resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
 		wwwAuth := resp.Header.Get("WWW-Authenticate")
 		if wwwAuth == "" {
-			return fmt.Errorf("unauthorized and no WWW-Authenticate header")
-		}
-
-		token, err := c.getAuthToken(ctx, registry, repo, wwwAuth)
-		if err != nil {
-			return fmt.Errorf("failed to get auth token: %v", err)
+			return nil, statusErrorf(resp.StatusCode, "unauthorized and no WWW-Authenticate header")
 		}
 
-		resp, err = c.doManifestRequest(ctx, manifestURL, acceptHeader, token)
+		done := traceStep(ctx, "auth token fetch")
+		token, err := c.getAuthToken(ctx, c.ref.repository, wwwAuth)
+		done(err)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("failed to get auth token: %w", err)
 		}
-		defer resp.Body.Close()
-	}
+		c.token = token
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("manifest check failed with status: %d", resp.StatusCode)
+		return c.doRequest(ctx, method, url, header, token)
 	}
 
-	return nil
+	return resp, nil
 }
 
-// doManifestRequest performs a HEAD request to the manifest URL with optional auth token.
-func (c *QuayCheck) doManifestRequest(ctx context.Context, url, acceptHeader, token string) (*http.Response, error) {
-	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+// doRequest performs a request to a registry URL with the header and an optional auth token.
+func (c *QuayCheck) doRequest(ctx context.Context, method, url string, header http.Header, token string) (
+	*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	req.Header.Set("Accept", acceptHeader)
+	for name, values := range header {
+		req.Header[name] = values
+	}
 	if token != "" {
 		req.Header.Set("Authorization", "Bearer "+token)
 	}
@@ -200,15 +294,39 @@ func (c *QuayCheck) doManifestRequest(ctx context.Context, url, acceptHeader, to
 // checkImage verifies that all configured tags are accessible via the registry API.
 func (c *QuayCheck) checkImage(ctx context.Context) (CheckResult, error) {
 	c.log.Printf("checking manifest for %s\n", c.getImage())
+	c.token = ""
+	if c.configErr != nil {
+		c.log.Printf("[ERROR] %s check failed: %v\n", c.name, c.configErr)
+		return CheckResult{1, "Failed", c.configErr.Error()}, c.configErr
+	}
 
 	for _, tag := range c.tags {
-		if tag == "" {
-			tag = "latest"
+		done := traceStep(ctx, "manifest HEAD", attribute.String("tag", tag))
+		digest, err := c.checkManifest(ctx, tag)
+		done(err)
+		if err != nil {
+			c.log.Printf("[ERROR] %s:%s check failed: %v\n", c.name, tag, err)
+			return CheckResult{1, "Failed", failureReason(err)}, err
 		}
 
-		if err := c.checkManifest(ctx, tag); err != nil {
-			c.log.Printf("[ERROR] %s:%s check failed: %v\n", c.name, tag, err)
-			return CheckResult{1, "Failed", err.Error()}, err
+		if c.options.PullLayers {
+			done := traceStep(ctx, "blobs", attribute.String("tag", tag))
+			err := c.checkBlobs(ctx, tag)
+			done(err)
+			if err != nil {
+				c.log.Printf("[ERROR] %s:%s blob check failed: %v\n", c.name, tag, err)
+				return CheckResult{1, "Failed", failureReason(err)}, err
+			}
+		}
+
+		if c.options.Signatures || c.options.Attestations {
+			done := traceStep(ctx, "signatures", attribute.String("tag", tag))
+			err := c.checkSignatures(ctx, tag, digest)
+			done(err)
+			if err != nil {
+				c.log.Printf("[ERROR] %s:%s signature check failed: %v\n", c.name, tag, err)
+				return CheckResult{1, "Failed", failureReason(err)}, err
+			}
 		}
 	}
 
@@ -216,19 +334,20 @@ func (c *QuayCheck) checkImage(ctx context.Context) (CheckResult, error) {
 	return CheckResult{0, "Succeeded", ""}, nil
 }
 
-// Check runs a QuayCheck and returns the float64 status required to save the prometheus data.
-func (c *QuayCheck) Check(ctx context.Context) float64 {
+// Check runs a QuayCheck and returns the float64 status required to save the prometheus data, along with
+// the error of a failed run.
+func (c *QuayCheck) Check(ctx context.Context) (float64, error) {
 	var reason string
 
 	c.log.Println("running quay check:", c.name)
 	result, err := c.checkImage(ctx)
 	if err != nil {
-		reason = err.Error()
+		reason = failureReason(err)
 	}
-	c.metric.Gauge.Record([]string{c.name}, metrics.FlipValue(result.code))
-	c.metric.Histogram.Record([]string{c.name, reason, result.status}, 1)
+	c.metric.Record(c.labels, result.code, reason)
+	traceResult(ctx, result.code, reason)
 
-	return result.code
+	return result.code, err
 }
 
 // getImage returns the image parameter of a QuayCheck instance.
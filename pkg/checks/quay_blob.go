@@ -0,0 +1,139 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// BLOB_REASON is the failure reason reported when the manifest resolves but its blobs can't be pulled.
+const BLOB_REASON = "blob"
+
+// maxManifestSize limits the size of a manifest read from the registry.
+const maxManifestSize = 4 * 1024 * 1024
+
+// descriptor describes a content addressable object referenced by a manifest.
+type descriptor struct {
+	MediaType string    `json:"mediaType"`
+	Digest    string    `json:"digest"`
+	Size      int64     `json:"size"`
+	Platform  *platform `json:"platform,omitempty"`
+}
+
+// platform describes the platform of an image referenced by a manifest list or an image index.
+type platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+// manifest holds the fields shared by image manifests, manifest lists and image indexes.
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Config    *descriptor  `json:"config,omitempty"`
+	Layers    []descriptor `json:"layers,omitempty"`
+	Manifests []descriptor `json:"manifests,omitempty"`
+}
+
+// getManifest fetches and decodes the manifest for the given reference, which can be a tag or a digest.
+func (c *QuayCheck) getManifest(ctx context.Context, reference string) (*manifest, error) {
+	resp, err := c.doRegistryRequest(ctx, "GET", c.repositoryURL("manifests", reference),
+		accept(manifestAcceptHeader))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusErrorf(resp.StatusCode, "manifest request for %s failed with status: %d", reference,
+			resp.StatusCode)
+	}
+
+	m := &manifest{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(m); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest for %s: %v", reference, err)
+	}
+
+	return m, nil
+}
+
+// resolveImageManifest returns the image manifest for the given tag. Manifest lists and image indexes
+// are resolved to the linux/amd64 image, or to the first image listed if that platform is not available.
+func (c *QuayCheck) resolveImageManifest(ctx context.Context, tag string) (*manifest, error) {
+	m, err := c.getManifest(ctx, tag)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Manifests) == 0 {
+		return m, nil
+	}
+
+	selected := m.Manifests[0]
+	for _, d := range m.Manifests {
+		if d.Platform != nil && d.Platform.OS == "linux" && d.Platform.Architecture == "amd64" {
+			selected = d
+			break
+		}
+	}
+
+	return c.getManifest(ctx, selected.Digest)
+}
+
+// checkBlob requests the first byte of a blob, following the redirects to the storage backend.
+func (c *QuayCheck) checkBlob(ctx context.Context, digest string) error {
+	// the client drops the bearer token when the registry redirects to a storage backend on a different
+	// host
+	resp, err := c.doRegistryRequest(ctx, "GET", c.repositoryURL("blobs", digest),
+		http.Header{"Range": []string{"bytes=0-0"}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return statusErrorf(resp.StatusCode, "blob %s request failed with status: %d", digest, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// checkBlobs resolves the image manifest for the given tag and verifies that its config and layer blobs
+// can be pulled. Failures are reported with the BLOB_REASON reason.
+func (c *QuayCheck) checkBlobs(ctx context.Context, tag string) error {
+	m, err := c.resolveImageManifest(ctx, tag)
+	if err != nil {
+		return newCheckError(BLOB_REASON, err)
+	}
+
+	blobs := m.Layers
+	if m.Config != nil {
+		blobs = append([]descriptor{*m.Config}, blobs...)
+	}
+	if len(blobs) == 0 {
+		return newCheckError(BLOB_REASON, fmt.Errorf("manifest for %s references no blobs", tag))
+	}
+
+	for _, blob := range blobs {
+		if err := c.checkBlob(ctx, blob.Digest); err != nil {
+			return newCheckError(BLOB_REASON, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const (
+	// SIGNATURE_REASON is the failure reason reported when a checked tag has no signature.
+	SIGNATURE_REASON = "signature"
+	// ATTESTATION_REASON is the failure reason reported when a checked tag has no attestation.
+	ATTESTATION_REASON = "attestation"
+
+	referrersMediaType = "application/vnd.oci.image.index.v1+json"
+)
+
+var (
+	// artifact types of the signatures found with the referrers API
+	signatureArtifactTypes = []string{
+		"application/vnd.dev.cosign.artifact.sig.v1+json",
+		"application/vnd.dev.sigstore.bundle.v0.3+json",
+	}
+	// artifact types of the attestations found with the referrers API
+	attestationArtifactTypes = []string{
+		"application/vnd.dev.cosign.artifact.att.v1+json",
+		"application/vnd.dsse.envelope.v1+json",
+		"application/vnd.in-toto+json",
+	}
+)
+
+// referrer describes a manifest returned by the referrers API.
+type referrer struct {
+	ArtifactType string `json:"artifactType"`
+	Digest       string `json:"digest"`
+}
+
+// artifactNotFoundError is the error of a signature or attestation missing from the registry.
+type artifactNotFoundError struct {
+	message string
+}
+
+// Error returns the error message.
+func (e *artifactNotFoundError) Error() string {
+	return e.message
+}
+
+// checkSignatures verifies that the manifest of the given tag has the signatures and attestations
+// required by the check options. Missing signatures and attestations are reported with the
+// SIGNATURE_REASON and ATTESTATION_REASON reasons, the errors of the registry with their own reasons.
+func (c *QuayCheck) checkSignatures(ctx context.Context, tag, digest string) error {
+	digest, err := c.resolveDigest(ctx, tag, digest)
+	if err != nil {
+		return err
+	}
+
+	var referrers []referrer
+	useReferrers := c.options.Referrers
+	if useReferrers {
+		referrers, useReferrers, err = c.getReferrers(ctx, digest)
+		if err != nil {
+			return err
+		}
+	}
+
+	if c.options.Signatures {
+		err := c.checkArtifact(ctx, digest, "sig", referrers, useReferrers, signatureArtifactTypes)
+		if err != nil {
+			return artifactError(SIGNATURE_REASON, err)
+		}
+	}
+	if c.options.Attestations {
+		err := c.checkArtifact(ctx, digest, "att", referrers, useReferrers, attestationArtifactTypes)
+		if err != nil {
+			return artifactError(ATTESTATION_REASON, err)
+		}
+	}
+
+	return nil
+}
+
+// artifactError returns a CheckError with the reason if the artifact is missing, otherwise the error of
+// the lookup, which keeps its own reason.
+func artifactError(reason string, err error) error {
+	var notFound *artifactNotFoundError
+	if errors.As(err, &notFound) {
+		return newCheckError(reason, err)
+	}
+
+	return err
+}
+
+// checkArtifact looks up an artifact of the given manifest digest, either in the referrers or by its
+// cosign tag, sha256-<hex>.<suffix>. An artifactNotFoundError is returned if it is missing.
+func (c *QuayCheck) checkArtifact(ctx context.Context, digest, suffix string, referrers []referrer,
+	useReferrers bool, artifactTypes []string) error {
+	if useReferrers {
+		for _, r := range referrers {
+			if slices.Contains(artifactTypes, r.ArtifactType) {
+				return nil
+			}
+		}
+		return &artifactNotFoundError{message: fmt.Sprintf("no %s referrer found for %s", suffix, digest)}
+	}
+
+	artifactTag := strings.Replace(digest, ":", "-", 1) + "." + suffix
+	_, err := c.checkManifest(ctx, artifactTag)
+	var status *statusError
+	if errors.As(err, &status) && status.code == http.StatusNotFound {
+		return &artifactNotFoundError{message: fmt.Sprintf("%s not found", artifactTag)}
+	}
+	if err != nil {
+		return fmt.Errorf("%s lookup failed: %w", artifactTag, err)
+	}
+
+	return nil
+}
+
+// getReferrers lists the referrers of the given manifest digest. The second return value is false if
+// the registry does not support the referrers API.
+func (c *QuayCheck) getReferrers(ctx context.Context, digest string) ([]referrer, bool, error) {
+	resp, err := c.doRegistryRequest(ctx, "GET", c.repositoryURL("referrers", digest), accept(referrersMediaType))
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		c.log.Printf("%s: referrers API not supported, falling back to the tag scheme\n", c.name)
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, statusErrorf(resp.StatusCode, "referrers request failed with status: %d", resp.StatusCode)
+	}
+
+	index := struct {
+		Manifests []referrer `json:"manifests"`
+	}{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&index); err != nil {
+		return nil, false, fmt.Errorf("failed to decode referrers of %s: %v", digest, err)
+	}
+
+	return index.Manifests, true, nil
+}
+
+// resolveDigest returns the manifest digest of a tag. The digest returned with the manifest check is used
+// if set, otherwise the manifest is fetched and hashed.
+func (c *QuayCheck) resolveDigest(ctx context.Context, tag, digest string) (string, error) {
+	if digest != "" {
+		return digest, nil
+	}
+	if strings.Contains(tag, ":") {
+		return tag, nil
+	}
+
+	resp, err := c.doRegistryRequest(ctx, "GET", c.repositoryURL("manifests", tag), accept(manifestAcceptHeader))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", statusErrorf(resp.StatusCode, "manifest request for %s failed with status: %d", tag, resp.StatusCode)
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.LimitReader(resp.Body, maxManifestSize)); err != nil {
+		return "", err
+	}
+
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
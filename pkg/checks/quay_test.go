@@ -0,0 +1,272 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hacbs-release/release-availability-metrics/pkg/metrics"
+)
+
+// registryHandler serves the v1 manifest of org/image.
+var registryHandler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodHead || req.URL.Path != "/v2/org/image/manifests/v1" {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Docker-Content-Digest", "sha256:"+strings.Repeat("0", 64))
+})
+
+// newTestQuayCheck returns a check of the v1 tag of org/image on the registry of server.
+func newTestQuayCheck(server *httptest.Server, options QuayOptions) *QuayCheck {
+	host := server.Listener.Addr().String()
+	return NewQuayCheck(NewQuayAuth(""), "quay", host+"/org/image:v1", nil, options, nil,
+		log.New(io.Discard, "", 0), metrics.NewCompositeMetric("test", nil))
+}
+
+func TestQuayCheckTLS(t *testing.T) {
+	server := httptest.NewTLSServer(registryHandler)
+	defer server.Close()
+	caBundle := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+
+	tests := []struct {
+		name    string
+		options QuayOptions
+		wantErr bool
+	}{
+		{"self-signed without CA", QuayOptions{}, true},
+		{"ca_bundle", QuayOptions{CaBundle: caBundle}, false},
+		{"insecure", QuayOptions{Insecure: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newTestQuayCheck(server, tt.options).Check(context.Background())
+			if tt.wantErr && (err == nil || !strings.Contains(err.Error(), "certificate")) {
+				t.Errorf("expected the certificate verification to fail, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestQuayCheckPlainHttp(t *testing.T) {
+	server := httptest.NewServer(registryHandler)
+	defer server.Close()
+
+	if _, err := newTestQuayCheck(server, QuayOptions{}).Check(context.Background()); err == nil {
+		t.Error("expected https to fail against a plain http registry")
+	}
+	if _, err := newTestQuayCheck(server, QuayOptions{PlainHttp: true}).Check(context.Background()); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestQuayCheckInvalidCaBundle(t *testing.T) {
+	server := httptest.NewTLSServer(registryHandler)
+	defer server.Close()
+
+	check := newTestQuayCheck(server, QuayOptions{CaBundle: "-----BEGIN CERTIFICATE-----\n-----END CERTIFICATE-----"})
+	if check.configErr == nil {
+		t.Error("expected an invalid CA bundle to be a configuration error")
+	}
+}
+
+func TestQuayCheckTransport(t *testing.T) {
+	server := httptest.NewServer(registryHandler)
+	defer server.Close()
+
+	transport := newTestQuayCheck(server, QuayOptions{Insecure: true}).client.Transport.(*http.Transport)
+	if transport.Proxy == nil || !transport.ForceAttemptHTTP2 || transport.IdleConnTimeout == 0 {
+		t.Error("the transport doesn't keep the settings of http.DefaultTransport")
+	}
+	if !transport.TLSClientConfig.InsecureSkipVerify {
+		t.Error("the transport doesn't use the TLS configuration of the check")
+	}
+}
+
+// signatureRegistry serves the v1 manifest of org/image. Its signature tag, or its referrers if
+// referrers is set, are answered with the status and the body.
+func signatureRegistry(t *testing.T, referrers bool, status int, body string) *httptest.Server {
+	digest := "sha256:" + strings.Repeat("0", 64)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/v2/org/image/manifests/v1":
+			w.Header().Set("Docker-Content-Digest", digest)
+		case "/v2/org/image/manifests/sha256-" + strings.Repeat("0", 64) + ".sig":
+			if referrers {
+				http.NotFound(w, req)
+				return
+			}
+			w.WriteHeader(status)
+		case "/v2/org/image/referrers/" + digest:
+			if !referrers {
+				http.NotFound(w, req)
+				return
+			}
+			w.WriteHeader(status)
+			io.WriteString(w, body)
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestQuayCheckSignatureReasons(t *testing.T) {
+	signed := `{"manifests": [{"artifactType": "application/vnd.dev.cosign.artifact.sig.v1+json"}]}`
+	tests := []struct {
+		name       string
+		referrers  bool
+		status     int
+		body       string
+		wantReason string
+	}{
+		{"signature tag", false, http.StatusOK, "", ""},
+		{"missing signature tag", false, http.StatusNotFound, "", SIGNATURE_REASON},
+		{"unauthorized signature tag", false, http.StatusUnauthorized, "", AUTH_REASON},
+		{"registry outage", false, http.StatusServiceUnavailable, "", HTTP_5XX_REASON},
+		{"signature referrer", true, http.StatusOK, signed, ""},
+		{"empty referrers", true, http.StatusOK, `{"manifests": []}`, SIGNATURE_REASON},
+		{"referrers outage", true, http.StatusInternalServerError, "", HTTP_5XX_REASON},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := signatureRegistry(t, tt.referrers, tt.status, tt.body)
+			check := newTestQuayCheck(server, QuayOptions{PlainHttp: true, Signatures: true, Referrers: tt.referrers})
+			_, err := check.Check(context.Background())
+			if tt.wantReason == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if reason := failureReason(err); reason != tt.wantReason {
+				t.Errorf("got the %q reason for %v, want %q", reason, err, tt.wantReason)
+			}
+		})
+	}
+}
+
+// blobRegistry requires a bearer token and serves the v1 tag of org/image as an index of an arm64 and an
+// amd64 image. Blobs are redirected to a storage host, which records the requested digests and whether
+// they carried an Authorization header. The blob endpoint only accepts the second token issued, so the
+// first blob request is answered with a challenge. Blobs not listed in available are not found.
+type blobRegistry struct {
+	registry  *httptest.Server
+	storage   *httptest.Server
+	available map[string]bool
+	tokens    int
+	fetched   []string
+	leaked    bool
+}
+
+func newBlobRegistry(t *testing.T) *blobRegistry {
+	r := &blobRegistry{available: map[string]bool{}}
+	images := map[string]string{}
+	var index []string
+	for _, arch := range []string{"arm64", "amd64"} {
+		config, layer := testDigest(arch+"-config"), testDigest(arch+"-layer")
+		r.available[config], r.available[layer] = true, true
+		image := testDigest(arch)
+		images[image] = `{"config": {"digest": "` + config + `"}, "layers": [{"digest": "` + layer + `"}]}`
+		index = append(index, `{"digest": "`+image+`", "platform": {"os": "linux", "architecture": "`+arch+`"}}`)
+	}
+	images["v1"] = `{"manifests": [` + strings.Join(index, ", ") + `]}`
+
+	r.storage = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.fetched = append(r.fetched, strings.TrimPrefix(req.URL.Path, "/"))
+		r.leaked = r.leaked || req.Header.Get("Authorization") != ""
+		w.WriteHeader(http.StatusPartialContent)
+	}))
+	t.Cleanup(r.storage.Close)
+
+	r.registry = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			r.tokens++
+			fmt.Fprintf(w, `{"token": "token-%d"}`, r.tokens)
+			return
+		}
+		blob, isBlob := strings.CutPrefix(req.URL.Path, "/v2/org/image/blobs/")
+		if auth := req.Header.Get("Authorization"); auth == "" || isBlob && auth != "Bearer token-2" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+r.registry.URL+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if isBlob {
+			if !r.available[blob] {
+				http.NotFound(w, req)
+				return
+			}
+			http.Redirect(w, req, r.storage.URL+"/"+blob, http.StatusTemporaryRedirect)
+			return
+		}
+		body, ok := images[strings.TrimPrefix(req.URL.Path, "/v2/org/image/manifests/")]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Docker-Content-Digest", testDigest("v1"))
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(r.registry.Close)
+
+	return r
+}
+
+// testDigest returns a sha256 digest of the content.
+func testDigest(content string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(content)))
+}
+
+func TestQuayCheckPullLayers(t *testing.T) {
+	r := newBlobRegistry(t)
+	check := newTestQuayCheck(r.registry, QuayOptions{PlainHttp: true, PullLayers: true})
+	if _, err := check.Check(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{testDigest("amd64-config"), testDigest("amd64-layer")}
+	if !slices.Equal(r.fetched, want) {
+		t.Errorf("fetched the %v blobs, want the amd64 ones %v", r.fetched, want)
+	}
+	if r.leaked {
+		t.Error("the bearer token was sent to the storage host")
+	}
+}
+
+func TestQuayCheckMissingBlob(t *testing.T) {
+	r := newBlobRegistry(t)
+	delete(r.available, testDigest("amd64-layer"))
+	check := newTestQuayCheck(r.registry, QuayOptions{PlainHttp: true, PullLayers: true})
+	_, err := check.Check(context.Background())
+	if reason := failureReason(err); reason != BLOB_REASON {
+		t.Errorf("got the %q reason for %v, want %q", reason, err, BLOB_REASON)
+	}
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	// DEFAULT_DOMAIN is the registry used for images without an explicit registry.
+	DEFAULT_DOMAIN = "docker.io"
+	// DEFAULT_DOMAIN_HOST is the host serving the registry API of DEFAULT_DOMAIN.
+	DEFAULT_DOMAIN_HOST = "registry-1.docker.io"
+	// OFFICIAL_REPO_PREFIX is the namespace of the official images in DEFAULT_DOMAIN.
+	OFFICIAL_REPO_PREFIX = "library/"
+
+	maxNameLength = 255
+)
+
+// regular expressions following the grammar of github.com/distribution/reference.
+var (
+	domainComponent = `(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])`
+	domainPattern   = `(?:` + domainComponent + `(?:\.` + domainComponent + `)*|\[[a-fA-F0-9:]+\])(?::[0-9]+)?`
+	pathComponent   = `[a-z0-9]+(?:(?:[._]|__|[-]+)[a-z0-9]+)*`
+	namePattern     = `(?:` + domainPattern + `/)?` + pathComponent + `(?:/` + pathComponent + `)*`
+	tagPattern      = `[\w][\w.-]{0,127}`
+	digestPattern   = `[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,}`
+
+	referenceRe = regexp.MustCompile(`^(` + namePattern + `)(?::(` + tagPattern + `))?(?:@(` + digestPattern + `))?$`)
+)
+
+// imageReference holds the parts of a parsed image reference.
+type imageReference struct {
+	domain     string
+	repository string
+	tag        string
+	digest     string
+}
+
+// parseImageReference parses a pull spec like quay.io/org/image:tag or image@sha256:<hex> into an
+// imageReference. Images without a registry are resolved to docker.io, and single component names on
+// docker.io get the library/ prefix of the official images.
+func parseImageReference(pullSpec string) (imageReference, error) {
+	ref := imageReference{}
+
+	// a scheme is not part of the grammar but is tolerated, as it is a common copy and paste leftover
+	spec := strings.TrimPrefix(strings.TrimPrefix(pullSpec, "https://"), "http://")
+
+	parts := referenceRe.FindStringSubmatch(spec)
+	if parts == nil {
+		return ref, fmt.Errorf("invalid image reference: %q", pullSpec)
+	}
+	name := parts[1]
+	ref.tag = parts[2]
+	ref.digest = parts[3]
+
+	if len(name) > maxNameLength {
+		return ref, fmt.Errorf("image name longer than %d characters: %q", maxNameLength, pullSpec)
+	}
+
+	domain, remainder, found := strings.Cut(name, "/")
+	if !found || !(strings.ContainsAny(domain, ".:") || domain == "localhost" || strings.ToLower(domain) != domain) {
+		domain = DEFAULT_DOMAIN
+		remainder = name
+	}
+	if domain == "index.docker.io" {
+		domain = DEFAULT_DOMAIN
+	}
+	if domain == DEFAULT_DOMAIN && !strings.Contains(remainder, "/") {
+		remainder = OFFICIAL_REPO_PREFIX + remainder
+	}
+	ref.domain = domain
+	ref.repository = remainder
+
+	return ref, nil
+}
+
+// host returns the host serving the registry API for the reference.
+func (r imageReference) host() string {
+	if r.domain == DEFAULT_DOMAIN {
+		return DEFAULT_DOMAIN_HOST
+	}
+
+	return r.domain
+}
+
+// references returns the tag and digest of the image reference, if set.
+func (r imageReference) references() []string {
+	var refs []string
+	if r.tag != "" {
+		refs = append(refs, r.tag)
+	}
+	if r.digest != "" {
+		refs = append(refs, r.digest)
+	}
+
+	return refs
+}
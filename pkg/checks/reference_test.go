@@ -0,0 +1,93 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseImageReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	tests := []struct {
+		pullSpec string
+		want     imageReference
+	}{
+		{"nginx", imageReference{domain: "docker.io", repository: "library/nginx"}},
+		{"nginx:1.25", imageReference{domain: "docker.io", repository: "library/nginx", tag: "1.25"}},
+		{"library/nginx", imageReference{domain: "docker.io", repository: "library/nginx"}},
+		{"org/image", imageReference{domain: "docker.io", repository: "org/image"}},
+		{"docker.io/x", imageReference{domain: "docker.io", repository: "library/x"}},
+		{"index.docker.io/x", imageReference{domain: "docker.io", repository: "library/x"}},
+		{"localhost/x", imageReference{domain: "localhost", repository: "x"}},
+		{"localhost:5000/x", imageReference{domain: "localhost:5000", repository: "x"}},
+		{"registry.example.com:8443/org/x:v1",
+			imageReference{domain: "registry.example.com:8443", repository: "org/x", tag: "v1"}},
+		{"quay.io/org/x@" + digest, imageReference{domain: "quay.io", repository: "org/x", digest: digest}},
+		{"quay.io/org/x:v1@" + digest,
+			imageReference{domain: "quay.io", repository: "org/x", tag: "v1", digest: digest}},
+		{"https://quay.io/org/x:v1", imageReference{domain: "quay.io", repository: "org/x", tag: "v1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pullSpec, func(t *testing.T) {
+			got, err := parseImageReference(tt.pullSpec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseImageReference(%q) = %+v, want %+v", tt.pullSpec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImageReferenceInvalid(t *testing.T) {
+	tests := map[string]string{
+		"uppercase repository": "quay.io/Org/image",
+		"empty tag":            "quay.io/org/image:",
+		"digest not hex":       "quay.io/org/image@sha256:" + strings.Repeat("z", 64),
+		"short digest":         "quay.io/org/image@sha256:abc",
+		"empty":                "",
+		"name too long":        "quay.io/" + strings.Repeat("a", maxNameLength),
+	}
+	for name, pullSpec := range tests {
+		t.Run(name, func(t *testing.T) {
+			if ref, err := parseImageReference(pullSpec); err == nil {
+				t.Errorf("parseImageReference(%q) = %+v, want an error", pullSpec, ref)
+			}
+		})
+	}
+}
+
+func TestImageReferenceHost(t *testing.T) {
+	tests := map[string]string{
+		"nginx":                 DEFAULT_DOMAIN_HOST,
+		"docker.io/org/x":       DEFAULT_DOMAIN_HOST,
+		"index.docker.io/org/x": DEFAULT_DOMAIN_HOST,
+		"quay.io/org/x":         "quay.io",
+		"localhost:5000/org/x":  "localhost:5000",
+	}
+	for pullSpec, want := range tests {
+		ref, err := parseImageReference(pullSpec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ref.host(); got != want {
+			t.Errorf("host of %q = %s, want %s", pullSpec, got, want)
+		}
+	}
+}
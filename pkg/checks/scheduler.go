@@ -0,0 +1,258 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/hacbs-release/release-availability-metrics/pkg/metrics"
+)
+
+// Check is implemented by every check type.
+type Check interface {
+	// Name returns the check name.
+	Name() string
+	// Check runs the check, records its metrics and returns 0 on success, along with the error of a
+	// failed run.
+	Check(ctx context.Context) (float64, error)
+	// Skip records that the check didn't run because the dependency is failing.
+	Skip(dependency string)
+}
+
+// checkBase holds the name, logger and metrics shared by the check types, which embed it to implement
+// Name and Skip.
+type checkBase struct {
+	name string
+	// labels are the label values of the series of the check
+	labels []string
+	log    *log.Logger
+	metric metrics.CompositeMetric
+}
+
+// Name returns the check name.
+func (c *checkBase) Name() string {
+	return c.name
+}
+
+// Skip records that the check was skipped because the dependency is failing.
+func (c *checkBase) Skip(dependency string) {
+	c.log.Printf("%s check skipped, %s did not succeed\n", c.name, dependency)
+	c.metric.Skip(c.labels)
+}
+
+// DependentCheck is a check which has to run after other checks.
+type DependentCheck interface {
+	Check
+	// Dependencies returns the names of the checks to run first.
+	Dependencies() []string
+}
+
+// SKIPPED_CODE is the result code of a check skipped because of a failing dependency.
+const SKIPPED_CODE = 2
+
+// Result is the result of a check in a run of the scheduler.
+type Result struct {
+	Name string
+	Code float64
+	// Err is the error of a failed run.
+	Err error
+	// SkippedBy is the failing dependency of a skipped check.
+	SkippedBy string
+	Duration  time.Duration
+}
+
+// Results holds the result codes of the last run of each check.
+type Results struct {
+	mu    sync.RWMutex
+	codes map[string]float64
+}
+
+// NewResults returns a new instance of Results.
+func NewResults() *Results {
+	return &Results{codes: map[string]float64{}}
+}
+
+// Get returns the result code of the last run of a check. False is returned if the check didn't run.
+func (r *Results) Get(name string) (float64, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	code, ok := r.codes[name]
+
+	return code, ok
+}
+
+// set stores the result code of a check.
+func (r *Results) set(name string, code float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.codes[name] = code
+}
+
+// Scheduler runs checks after the checks they depend on.
+type Scheduler struct {
+	order     []Check
+	dependsOn map[string][]string
+	results   *Results
+}
+
+// NewScheduler returns a new instance of Scheduler running the checks in their given order, moving
+// dependent checks after their dependencies. The checks in dependsOn are skipped while one of their
+// dependencies is failing. An error is returned when two checks share a name, the dependencies form a
+// cycle or a check depends on an unknown check. Unknown checks aggregated by a DependentCheck are left to
+// it to report.
+func NewScheduler(checks []Check, dependsOn map[string][]string, results *Results) (*Scheduler, error) {
+	byName := map[string]Check{}
+	for _, check := range checks {
+		if _, ok := byName[check.Name()]; ok {
+			return nil, fmt.Errorf("duplicate check name %s", check.Name())
+		}
+		byName[check.Name()] = check
+	}
+	for _, check := range checks {
+		for _, dependency := range dependsOn[check.Name()] {
+			if _, ok := byName[dependency]; !ok {
+				return nil, fmt.Errorf("check %s depends on unknown check %s", check.Name(), dependency)
+			}
+		}
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	order := []Check{}
+	var visit func(check Check, path []string) error
+	visit = func(check Check, path []string) error {
+		name := check.Name()
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("check dependency cycle: %s", strings.Join(append(path, name), " -> "))
+		}
+		state[name] = visiting
+		dependencies := dependsOn[name]
+		if dependent, ok := check.(DependentCheck); ok {
+			dependencies = append(append([]string{}, dependent.Dependencies()...), dependencies...)
+		}
+		for _, dependency := range dependencies {
+			if dep, ok := byName[dependency]; ok {
+				if err := visit(dep, append(path, name)); err != nil {
+					return err
+				}
+			}
+		}
+		state[name] = visited
+		order = append(order, check)
+
+		return nil
+	}
+	for _, check := range checks {
+		if err := visit(check, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Scheduler{order: order, dependsOn: dependsOn, results: results}, nil
+}
+
+// Select returns the named checks along with the checks they depend on, keeping their given order, so that
+// a subset of the checks can be scheduled. An error is returned for an unknown check name.
+func Select(checks []Check, dependsOn map[string][]string, names []string) ([]Check, error) {
+	byName := map[string]Check{}
+	for _, check := range checks {
+		byName[check.Name()] = check
+	}
+
+	selected := map[string]bool{}
+	var add func(name string)
+	add = func(name string) {
+		check, ok := byName[name]
+		if !ok || selected[name] {
+			return
+		}
+		selected[name] = true
+		for _, dependency := range dependsOn[name] {
+			add(dependency)
+		}
+		if dependent, ok := check.(DependentCheck); ok {
+			for _, dependency := range dependent.Dependencies() {
+				add(dependency)
+			}
+		}
+	}
+	for _, name := range names {
+		if _, ok := byName[name]; !ok {
+			return nil, fmt.Errorf("unknown check %s", name)
+		}
+		add(name)
+	}
+
+	subset := []Check{}
+	for _, check := range checks {
+		if selected[check.Name()] {
+			subset = append(subset, check)
+		}
+	}
+
+	return subset, nil
+}
+
+// Run runs all the checks once, stores their results and returns them in the order the checks ran. Checks
+// with a failed or skipped dependency are skipped, so that only the root cause of a failure shows as down.
+// Every run, or skip, is traced in its own span.
+func (s *Scheduler) Run(ctx context.Context) []Result {
+	results := []Result{}
+	for _, check := range s.order {
+		checkCtx, span := startSpan(ctx, check.Name())
+		if dependency := s.failingDependency(check.Name()); dependency != "" {
+			check.Skip(dependency)
+			s.results.set(check.Name(), SKIPPED_CODE)
+			span.SetAttributes(attribute.String("check.skipped_by", dependency))
+			span.End()
+			results = append(results, Result{Name: check.Name(), Code: SKIPPED_CODE, SkippedBy: dependency})
+			continue
+		}
+
+		start := time.Now()
+		code, err := check.Check(checkCtx)
+		s.results.set(check.Name(), code)
+		span.End()
+		results = append(results, Result{Name: check.Name(), Code: code, Err: err, Duration: time.Since(start)})
+	}
+
+	return results
+}
+
+// failingDependency returns the first dependency of a check which failed or was skipped in the current
+// run, or an empty string.
+func (s *Scheduler) failingDependency(name string) string {
+	for _, dependency := range s.dependsOn[name] {
+		if code, ok := s.results.Get(dependency); ok && code != 0 {
+			return dependency
+		}
+	}
+
+	return ""
+}
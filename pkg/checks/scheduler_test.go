@@ -0,0 +1,180 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// fakeCheck is a check returning a fixed code.
+type fakeCheck struct {
+	name string
+	code float64
+}
+
+func (c *fakeCheck) Name() string { return c.name }
+
+func (c *fakeCheck) Check(context.Context) (float64, error) { return c.code, nil }
+
+func (c *fakeCheck) Skip(string) {}
+
+// fakeDependentCheck is a check aggregating other checks, like a composite check.
+type fakeDependentCheck struct {
+	fakeCheck
+	dependencies []string
+}
+
+func (c *fakeDependentCheck) Dependencies() []string { return c.dependencies }
+
+// names returns the names of the checks.
+func names(checks []Check) []string {
+	names := []string{}
+	for _, check := range checks {
+		names = append(names, check.Name())
+	}
+
+	return names
+}
+
+func TestSelect(t *testing.T) {
+	all := []Check{
+		&fakeCheck{name: "dns"},
+		&fakeCheck{name: "github"},
+		&fakeCheck{name: "quay"},
+		&fakeCheck{name: "http"},
+		&fakeDependentCheck{fakeCheck: fakeCheck{name: "release"}, dependencies: []string{"github", "quay"}},
+		&fakeCheck{name: "unrelated"},
+	}
+	dependsOn := map[string][]string{
+		"github": {"dns"},
+		"quay":   {"http"},
+	}
+
+	tests := map[string]struct {
+		selected []string
+		want     []string
+	}{
+		"single check":            {[]string{"http"}, []string{"http"}},
+		"depends_on":              {[]string{"github"}, []string{"dns", "github"}},
+		"composite dependencies":  {[]string{"release"}, []string{"dns", "github", "quay", "http", "release"}},
+		"overlapping selections":  {[]string{"quay", "http"}, []string{"quay", "http"}},
+		"given order kept":        {[]string{"unrelated", "dns"}, []string{"dns", "unrelated"}},
+		"selected more than once": {[]string{"dns", "dns"}, []string{"dns"}},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			subset, err := Select(all, dependsOn, tt.selected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := names(subset); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select(%v) = %v, want %v", tt.selected, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Select(all, dependsOn, []string{"missing"}); err == nil {
+		t.Error("expected an error for an unknown check")
+	}
+}
+
+func TestNewSchedulerOrder(t *testing.T) {
+	all := []Check{
+		&fakeDependentCheck{fakeCheck: fakeCheck{name: "release"}, dependencies: []string{"github", "quay"}},
+		&fakeCheck{name: "github"},
+		&fakeCheck{name: "quay"},
+		&fakeCheck{name: "dns"},
+		&fakeCheck{name: "http"},
+	}
+	dependsOn := map[string][]string{
+		"github": {"dns"},
+		"quay":   {"http"},
+	}
+
+	scheduler, err := NewScheduler(all, dependsOn, NewResults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"dns", "github", "http", "quay", "release"}
+	if got := names(scheduler.order); !reflect.DeepEqual(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func TestNewSchedulerErrors(t *testing.T) {
+	tests := map[string]struct {
+		checks    []Check
+		dependsOn map[string][]string
+	}{
+		"cycle": {
+			[]Check{&fakeCheck{name: "a"}, &fakeCheck{name: "b"}, &fakeCheck{name: "c"}},
+			map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}},
+		},
+		"self dependency": {
+			[]Check{&fakeCheck{name: "a"}},
+			map[string][]string{"a": {"a"}},
+		},
+		"cycle through a composite check": {
+			[]Check{&fakeDependentCheck{fakeCheck: fakeCheck{name: "release"}, dependencies: []string{"a"}},
+				&fakeCheck{name: "a"}},
+			map[string][]string{"a": {"release"}},
+		},
+		"unknown dependency": {
+			[]Check{&fakeCheck{name: "a"}},
+			map[string][]string{"a": {"missing"}},
+		},
+		"duplicate name": {
+			[]Check{&fakeCheck{name: "a"}, &fakeCheck{name: "a"}},
+			nil,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewScheduler(tt.checks, tt.dependsOn, NewResults()); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestSchedulerRunSkipsDependents(t *testing.T) {
+	all := []Check{
+		&fakeCheck{name: "dns", code: 1},
+		&fakeCheck{name: "github"},
+		&fakeCheck{name: "quay"},
+		&fakeCheck{name: "signature"},
+	}
+	dependsOn := map[string][]string{
+		"github":    {"dns"},
+		"signature": {"github"},
+	}
+
+	scheduler, err := NewScheduler(all, dependsOn, NewResults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	skippedBy := map[string]string{}
+	for _, result := range scheduler.Run(context.Background()) {
+		skippedBy[result.Name] = result.SkippedBy
+	}
+	// a skipped check skips its own dependents
+	want := map[string]string{"dns": "", "github": "dns", "quay": "", "signature": "github"}
+	if !reflect.DeepEqual(skippedBy, want) {
+		t.Errorf("skipped by %v, want %v", skippedBy, want)
+	}
+}
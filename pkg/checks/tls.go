@@ -0,0 +1,60 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// newTLSConfig returns a tls.Config trusting the system roots and the certificates of caBundle, which can
+// be PEM data or the path to a PEM file. Certificate verification is disabled if insecure is set.
+func newTLSConfig(insecure bool, caBundle string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: insecure,
+	}
+	if caBundle == "" {
+		return tlsConfig, nil
+	}
+
+	data, err := loadPEM(caBundle)
+	if err != nil {
+		return tlsConfig, fmt.Errorf("failed to read CA bundle: %v", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return tlsConfig, fmt.Errorf("no certificates found in CA bundle")
+	}
+	tlsConfig.RootCAs = pool
+
+	return tlsConfig, nil
+}
+
+// loadPEM returns value if it holds PEM data, otherwise the content of the file value points to.
+func loadPEM(value string) ([]byte, error) {
+	if strings.Contains(value, "-----BEGIN") {
+		return []byte(value), nil
+	}
+
+	return os.ReadFile(value)
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is the instrumentation scope of the check spans.
+const tracerName = "github.com/hacbs-release/release-availability-metrics/pkg/checks"
+
+// startSpan starts the span of a check run. Spans are only exported when a tracer provider is set, they
+// are no-ops otherwise.
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, "check "+name,
+		trace.WithAttributes(attribute.String("check.name", name)))
+}
+
+// traceStep starts a sub-step of the check run. The returned function adds it as an event of the run
+// span, along with its duration and error, if any.
+func traceStep(ctx context.Context, name string, attrs ...attribute.KeyValue) func(err error) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return func(error) {}
+	}
+
+	start := time.Now()
+	return func(err error) {
+		attrs = append(attrs, attribute.Int64("duration_ms", time.Since(start).Milliseconds()))
+		if err != nil {
+			attrs = append(attrs, attribute.String("error", err.Error()))
+		}
+		span.AddEvent(name, trace.WithAttributes(attrs...))
+	}
+}
+
+// traceResult sets the result of the check run on its span.
+func traceResult(ctx context.Context, code float64, reason string) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int("check.result", int(code)))
+	if code != 0 {
+		span.SetStatus(codes.Error, reason)
+	}
+}
@@ -15,9 +15,132 @@ limitations under the License.
 */
 package checks
 
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// Generic failure reasons, the reasons specific to a check type being declared along with it. The
+// failure reasons are label values, so they are kept to a fixed set.
+const (
+	TIMEOUT_REASON    = "timeout"
+	DNS_REASON        = "dns"
+	CONNECTION_REASON = "connection"
+	TLS_REASON        = "tls"
+	AUTH_REASON       = "auth"
+	HTTP_4XX_REASON   = "http_4xx"
+	HTTP_5XX_REASON   = "http_5xx"
+	NOT_FOUND_REASON  = "not found"
+	OTHER_REASON      = "other"
+)
+
 // CheckResult
 type CheckResult struct {
 	code   float64
 	status string
 	reason string
 }
+
+// CheckError is an error that carries a short reason used to label the check failure in the metrics.
+type CheckError struct {
+	reason string
+	err    error
+}
+
+// newCheckError returns a new instance of CheckError.
+func newCheckError(reason string, err error) *CheckError {
+	return &CheckError{
+		reason: reason,
+		err:    err,
+	}
+}
+
+// Error returns the error message prefixed with its reason.
+func (e *CheckError) Error() string {
+	return fmt.Sprintf("%s: %s", e.reason, e.err.Error())
+}
+
+// Unwrap returns the wrapped error.
+func (e *CheckError) Unwrap() error {
+	return e.err
+}
+
+// statusError is the error of an HTTP request answered with an unexpected status.
+type statusError struct {
+	code    int
+	message string
+}
+
+// statusErrorf returns a new statusError for the status code with a formatted message.
+func statusErrorf(code int, format string, a ...interface{}) error {
+	return &statusError{code: code, message: fmt.Sprintf(format, a...)}
+}
+
+// Error returns the error message.
+func (e *statusError) Error() string {
+	return e.message
+}
+
+// failureReason classifies an error in one of the failure reasons: the reason of a CheckError, or one of
+// the generic reasons. OTHER_REASON is returned for unknown errors.
+func failureReason(err error) string {
+	var checkErr *CheckError
+	var status *statusError
+	var netErr net.Error
+	var dnsErr *net.DNSError
+	var opErr *net.OpError
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+
+	switch {
+	case errors.As(err, &checkErr):
+		return checkErr.reason
+	case errors.As(err, &status):
+		return statusReason(status.code)
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return TIMEOUT_REASON
+	case errors.As(err, &dnsErr):
+		return DNS_REASON
+	case errors.As(err, &certErr), errors.As(err, &recordErr), errors.As(err, &authorityErr),
+		errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return TLS_REASON
+	case errors.Is(err, transport.ErrAuthenticationRequired), errors.Is(err, transport.ErrAuthorizationFailed),
+		errors.Is(err, transport.ErrInvalidAuthMethod):
+		return AUTH_REASON
+	case errors.Is(err, transport.ErrRepositoryNotFound), errors.Is(err, object.ErrFileNotFound),
+		errors.Is(err, plumbing.ErrObjectNotFound):
+		return NOT_FOUND_REASON
+	case errors.As(err, &opErr):
+		return CONNECTION_REASON
+	}
+
+	return OTHER_REASON
+}
+
+// statusReason returns the failure reason of an HTTP status code.
+func statusReason(code int) string {
+	switch {
+	case code == http.StatusUnauthorized, code == http.StatusForbidden:
+		return AUTH_REASON
+	case code == http.StatusNotFound:
+		return NOT_FOUND_REASON
+	case code >= 500:
+		return HTTP_5XX_REASON
+	case code >= 400:
+		return HTTP_4XX_REASON
+	}
+
+	return OTHER_REASON
+}
@@ -0,0 +1,63 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package checks
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+func TestFailureReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{newCheckError(STALE_REASON, errors.New("commit is 9 days old")), STALE_REASON},
+		{fmt.Errorf("check quay: %w", newCheckError(BLOB_REASON, errors.New("blob sha256:0 missing"))), BLOB_REASON},
+		{statusErrorf(http.StatusUnauthorized, "401 Unauthorized"), AUTH_REASON},
+		{statusErrorf(http.StatusForbidden, "403 Forbidden"), AUTH_REASON},
+		{statusErrorf(http.StatusNotFound, "manifest check failed with status: 404"), NOT_FOUND_REASON},
+		{statusErrorf(http.StatusTooManyRequests, "429 Too Many Requests"), HTTP_4XX_REASON},
+		{statusErrorf(http.StatusBadGateway, "502 Bad Gateway"), HTTP_5XX_REASON},
+		{fmt.Errorf("failed to get auth token: %w", statusErrorf(http.StatusServiceUnavailable, "503")), HTTP_5XX_REASON},
+		{&url.Error{Op: "Get", URL: "https://quay.io/v2/", Err: context.DeadlineExceeded}, TIMEOUT_REASON},
+		{&net.DNSError{Err: "no such host", Name: "quay.io", IsNotFound: true}, DNS_REASON},
+		{&net.DNSError{Err: "i/o timeout", Name: "quay.io", IsTimeout: true}, TIMEOUT_REASON},
+		{&url.Error{Op: "Get", URL: "https://10.0.0.1/", Err: &net.OpError{Op: "dial", Net: "tcp",
+			Err: syscall.ECONNREFUSED}}, CONNECTION_REASON},
+		{&url.Error{Op: "Get", URL: "https://quay.io/", Err: x509.UnknownAuthorityError{}}, TLS_REASON},
+		{x509.HostnameError{Certificate: &x509.Certificate{}, Host: "quay.io"}, TLS_REASON},
+		{transport.ErrAuthenticationRequired, AUTH_REASON},
+		{transport.ErrRepositoryNotFound, NOT_FOUND_REASON},
+		{object.ErrFileNotFound, NOT_FOUND_REASON},
+		{errors.New("unexpected EOF at 10.1.2.3:443 after 1.2s"), OTHER_REASON},
+	}
+	for _, tt := range tests {
+		if got := failureReason(tt.err); got != tt.want {
+			t.Errorf("failureReason(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -1,26 +1,94 @@
 package config
 
 import (
+	"fmt"
 	"gopkg.in/yaml.v3"
 	"os"
+	"time"
 )
 
 // GitCheck is a structure type to store config for a Git check
 type GitCheckConfig struct {
-	Name     string `yaml:"name"`
-	Url      string `yaml:"url"`
-	Revision string `yaml:"revision"`
-	Path     string `yaml:"path"`
-	Token    string `yaml:"token"`
+	Name           string        `yaml:"name"`
+	Url            string        `yaml:"url"`
+	Revision       string        `yaml:"revision"`
+	Path           StringList    `yaml:"path"`
+	Token          string        `yaml:"token"`
+	Mode           string        `yaml:"mode"`
+	SSHKey         string        `yaml:"ssh_key"`
+	SSHPassphrase  string        `yaml:"ssh_key_passphrase"`
+	KnownHosts     string        `yaml:"known_hosts"`
+	KnownHostsFile string        `yaml:"known_hosts_file"`
+	RefType        string        `yaml:"ref_type"`
+	ContentRegex   string        `yaml:"content_regex"`
+	Sha256         string        `yaml:"sha256"`
+	YamlPath       string        `yaml:"yaml_path"`
+	JsonPath       string        `yaml:"json_path"`
+	MaxCommitAge   Duration      `yaml:"max_commit_age"`
+	Provider       string        `yaml:"provider"`
+	ApiUrl         string        `yaml:"api_url"`
+	CacheDir       string        `yaml:"cache_dir"`
+	Auth           GitAuthConfig `yaml:"auth"`
+	TrustedKeys    StringList    `yaml:"trusted_keys"`
+	// Labels are static labels set on every series of the check
+	Labels map[string]string `yaml:"labels"`
+	// SLO is the availability objective of the check
+	SLO *SLOConfig `yaml:"slo"`
+	// DependsOn are the checks which have to succeed for the check to run
+	DependsOn []string `yaml:"depends_on"`
+}
+
+// GitAuthConfig is a structure type to store the authentication config of a Git check
+type GitAuthConfig struct {
+	Username  string           `yaml:"username"`
+	GithubApp *GithubAppConfig `yaml:"github_app"`
+}
+
+// GithubAppConfig is a structure type to store the GitHub App installation used by a Git check
+type GithubAppConfig struct {
+	AppId          int64  `yaml:"app_id"`
+	InstallationId int64  `yaml:"installation_id"`
+	PrivateKey     string `yaml:"private_key"`
+}
+
+// StringList is a list of strings which can also be set from a single string
+type StringList []string
+
+// UnmarshalYAML decodes a single string or a list of strings into a StringList
+func (l *StringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = StringList{value.Value}
+		return nil
+	}
+
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*l = list
+
+	return nil
 }
 
 // QuayCheck is a structure type to store config for a Quay check
 type QuayCheckConfig struct {
-	Name     string   `yaml:"name"`
-	PullSpec string   `yaml:"pullspec"`
-	Tags     []string `yaml:"tags"`
-	Username string   `yaml:"username"`
-	Password string   `yaml:"password"`
+	Name         string   `yaml:"name"`
+	PullSpec     string   `yaml:"pullspec"`
+	Tags         []string `yaml:"tags"`
+	AuthFile     string   `yaml:"auth_file"`
+	PullLayers   bool     `yaml:"pull_layers"`
+	Insecure     bool     `yaml:"insecure"`
+	PlainHttp    bool     `yaml:"plain_http"`
+	CaBundle     string   `yaml:"ca_bundle"`
+	Signatures   bool     `yaml:"signatures"`
+	Attestations bool     `yaml:"attestations"`
+	Referrers    bool     `yaml:"referrers"`
+	// Labels are static labels set on every series of the check
+	Labels map[string]string `yaml:"labels"`
+	// SLO is the availability objective of the check
+	SLO *SLOConfig `yaml:"slo"`
+	// DependsOn are the checks which have to succeed for the check to run
+	DependsOn []string `yaml:"depends_on"`
 }
 
 // GitCheck is a structure type to store config for a Git check
@@ -33,6 +101,28 @@ type HttpCheckConfig struct {
 	Key      string `yaml:"key"`
 	Insecure bool   `yaml:"insecure"`
 	Follow   bool   `yaml:"follow_redirect"`
+	// Labels are static labels set on every series of the check
+	Labels map[string]string `yaml:"labels"`
+	// SLO is the availability objective of the check
+	SLO *SLOConfig `yaml:"slo"`
+	// DependsOn are the checks which have to succeed for the check to run
+	DependsOn []string `yaml:"depends_on"`
+}
+
+// CompositeCheckConfig is a structure type to store config for a Composite check
+type CompositeCheckConfig struct {
+	Name      string             `yaml:"name"`
+	Mode      string             `yaml:"mode"`
+	Checks    []string           `yaml:"checks"`
+	Min       int                `yaml:"min"`
+	Weights   map[string]float64 `yaml:"weights"`
+	Threshold float64            `yaml:"threshold"`
+	// Labels are static labels set on every series of the check
+	Labels map[string]string `yaml:"labels"`
+	// SLO is the availability objective of the check
+	SLO *SLOConfig `yaml:"slo"`
+	// DependsOn are the checks which have to succeed for the check to run
+	DependsOn []string `yaml:"depends_on"`
 }
 
 // ServiceConfig is a structure type to store the configs for the service
@@ -41,18 +131,104 @@ type ServiceConfig struct {
 	ListenPort    int    `yaml:"listen_port"`
 	PollInterval  int    `yaml:"pool_interval"`
 	MetricsPrefix string `yaml:"metrics_prefix"`
+	// RuntimeMetrics also exports the go runtime and process metrics
+	RuntimeMetrics bool `yaml:"runtime_metrics"`
+	// StateDir persists the state of the service, like the SLO run history, across restarts
+	StateDir string `yaml:"state_dir"`
+	// ApiToken is the bearer token required by the silences API, which is only served when it is set
+	ApiToken string `yaml:"api_token"`
+	// AllowUnauthenticatedApi serves the silences API without a token
+	AllowUnauthenticatedApi bool `yaml:"allow_unauthenticated_api"`
+	// OTLP pushes the metrics and the check spans to an OpenTelemetry collector, if set
+	OTLP *OTLPConfig `yaml:"otlp"`
+	// Push pushes the metrics to a Pushgateway or a remote-write endpoint after each round, if set
+	Push *PushConfig `yaml:"push"`
+}
+
+// PushConfig is a structure type to store the Pushgateway or remote-write endpoint the metrics are pushed to
+type PushConfig struct {
+	Url         string   `yaml:"url"`
+	Mode        string   `yaml:"mode"`
+	Job         string   `yaml:"job"`
+	Instance    string   `yaml:"instance"`
+	Username    string   `yaml:"username"`
+	Password    string   `yaml:"password"`
+	BearerToken string   `yaml:"bearer_token"`
+	Timeout     Duration `yaml:"timeout"`
+}
+
+// OTLPConfig is a structure type to store the OTLP receiver the metrics and spans are exported to
+type OTLPConfig struct {
+	Endpoint string            `yaml:"endpoint"`
+	Protocol string            `yaml:"protocol"`
+	Insecure bool              `yaml:"insecure"`
+	Headers  map[string]string `yaml:"headers"`
+	Interval Duration          `yaml:"interval"`
+}
+
+// MaintenanceWindowConfig is a structure type to store a scheduled maintenance window, either starting
+// on a cron schedule or set with absolute start and end times
+type MaintenanceWindowConfig struct {
+	Name     string            `yaml:"name"`
+	Checks   []string          `yaml:"checks"`
+	Selector map[string]string `yaml:"selector"`
+	Cron     string            `yaml:"cron"`
+	Duration Duration          `yaml:"duration"`
+	Timezone string            `yaml:"timezone"`
+	Start    time.Time         `yaml:"start"`
+	End      time.Time         `yaml:"end"`
+}
+
+// SLOConfig is a structure type to store the availability objective of a check
+type SLOConfig struct {
+	// Target is the availability objective in percent, like 99.5
+	Target float64  `yaml:"target"`
+	Window Duration `yaml:"window"`
+}
+
+// SLOGroupConfig is a structure type to store the availability objective of a group of checks, selected
+// like the checks of a maintenance window
+type SLOGroupConfig struct {
+	Name     string            `yaml:"name"`
+	Checks   []string          `yaml:"checks"`
+	Selector map[string]string `yaml:"selector"`
+	// Target is the availability objective in percent, like 99.5
+	Target float64  `yaml:"target"`
+	Window Duration `yaml:"window"`
 }
 
 // CheckConfig is a structure type to store check configuration
 type CheckConfig struct {
-	Git  []GitCheckConfig  `yaml:"git"`
-	Quay []QuayCheckConfig `yaml:"quay"`
-	Http []HttpCheckConfig `yaml:"http"`
+	Git       []GitCheckConfig       `yaml:"git"`
+	Quay      []QuayCheckConfig      `yaml:"quay"`
+	Http      []HttpCheckConfig      `yaml:"http"`
+	Composite []CompositeCheckConfig `yaml:"composite"`
+}
+
+// Names returns the names of the checks of all types.
+func (c CheckConfig) Names() []string {
+	names := []string{}
+	for _, check := range c.Git {
+		names = append(names, check.Name)
+	}
+	for _, check := range c.Quay {
+		names = append(names, check.Name)
+	}
+	for _, check := range c.Http {
+		names = append(names, check.Name)
+	}
+	for _, check := range c.Composite {
+		names = append(names, check.Name)
+	}
+
+	return names
 }
 
 type Config struct {
-	Service ServiceConfig `yaml:"service"`
-	Checks  CheckConfig   `yaml:"checks"`
+	Service     ServiceConfig             `yaml:"service"`
+	Checks      CheckConfig               `yaml:"checks"`
+	Maintenance []MaintenanceWindowConfig `yaml:"maintenance"`
+	SLOs        []SLOGroupConfig          `yaml:"slos"`
 }
 
 func LoadConfig(configFile string) (Config, error) {
@@ -66,5 +242,17 @@ func LoadConfig(configFile string) (Config, error) {
 		return cfg, err
 	}
 
+	// the checks of all types are scheduled, and their results and metrics stored, by name
+	names := map[string]bool{}
+	for _, name := range cfg.Checks.Names() {
+		if name == "" || name == "." || name == ".." {
+			return cfg, fmt.Errorf("invalid check name %q", name)
+		}
+		if names[name] {
+			return cfg, fmt.Errorf("duplicate check name %s", name)
+		}
+		names[name] = true
+	}
+
 	return cfg, nil
 }
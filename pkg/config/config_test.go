@@ -0,0 +1,106 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeConfig writes the config to a file and returns its path.
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoadConfigDuplicateNames(t *testing.T) {
+	path := writeConfig(t, `
+checks:
+  git:
+    - name: release
+      url: https://github.com/org/repo
+  http:
+    - name: release
+      url: https://example.com
+`)
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("expected an error for a check name shared by two check types")
+	}
+}
+
+func TestLoadConfigInvalidNames(t *testing.T) {
+	for _, name := range []string{`""`, ".", ".."} {
+		path := writeConfig(t, `
+checks:
+  git:
+    - name: `+name+`
+      url: https://github.com/org/repo
+`)
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("expected an error for the %s check name", name)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := writeConfig(t, `
+checks:
+  git:
+    - name: github
+      url: https://github.com/org/repo
+  http:
+    - name: http
+      url: https://example.com
+  composite:
+    - name: release
+      checks: [github, http]
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := cfg.Checks.Names(); len(names) != 3 {
+		t.Errorf("got the checks %v, want 3", names)
+	}
+}
+
+func TestLoadConfigMaxCommitAge(t *testing.T) {
+	path := writeConfig(t, `
+checks:
+  git:
+    - name: days
+      url: https://github.com/org/repo
+      max_commit_age: 7d
+    - name: hours
+      url: https://github.com/org/repo
+      max_commit_age: 36h
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range []time.Duration{7 * 24 * time.Hour, 36 * time.Hour} {
+		if got := time.Duration(cfg.Checks.Git[i].MaxCommitAge); got != want {
+			t.Errorf("max_commit_age of %s = %v, want %v", cfg.Checks.Git[i].Name, got, want)
+		}
+	}
+}
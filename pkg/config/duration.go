@@ -0,0 +1,84 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is a time.Duration which can also be set in days or weeks, like 30d, 2w or 1d12h
+type Duration time.Duration
+
+// durationUnits are the units of a duration which time.ParseDuration doesn't support
+var durationUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// durationUnitRe matches a leading whole number of days or weeks
+var durationUnitRe = regexp.MustCompile(`^(\d+)([dw])`)
+
+// ParseDuration parses a positive Go duration, which can start with whole numbers of weeks and days,
+// like 2w3d or 1d12h
+func ParseDuration(value string) (time.Duration, error) {
+	if strings.ContainsAny(value, "+-") {
+		return 0, fmt.Errorf("duration must be positive: %s", value)
+	}
+
+	var duration time.Duration
+	rest := value
+	for {
+		match := durationUnitRe.FindStringSubmatch(rest)
+		if match == nil {
+			break
+		}
+		n, err := strconv.Atoi(match[1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %s", value)
+		}
+		duration += time.Duration(n) * durationUnits[match[2]]
+		rest = rest[len(match[0]):]
+	}
+	if rest != "" || value == "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %s", value)
+		}
+		duration += d
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("duration must be positive: %s", value)
+	}
+
+	return duration, nil
+}
+
+// UnmarshalYAML decodes a duration string into a Duration
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	duration, err := ParseDuration(value.Value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+
+	return nil
+}
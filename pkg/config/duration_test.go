@@ -0,0 +1,59 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"90m", 90 * time.Minute, false},
+		{"36h", 36 * time.Hour, false},
+		{"30d", 30 * day, false},
+		{"2w", 14 * day, false},
+		{"1d12h", 36 * time.Hour, false},
+		{"2w3d", 17 * day, false},
+		{"1w2d3h30m", 9*day + 3*time.Hour + 30*time.Minute, false},
+		{"", 0, true},
+		{"d", 0, true},
+		{"1.5d", 0, true},
+		{"12h1d", 0, true},
+		{"1x", 0, true},
+		{"0", 0, true},
+		{"0d", 0, true},
+		{"-1d", 0, true},
+		{"-5m", 0, true},
+		{"1d-12h", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseDuration(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package maintenance
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/hacbs-release/release-availability-metrics/pkg/config"
+)
+
+// silenceRequest is the body of a silence creation request. The end of the silence is set with either
+// EndsAt or Duration.
+type silenceRequest struct {
+	Checks    []string          `json:"checks"`
+	Selector  map[string]string `json:"selector"`
+	StartsAt  time.Time         `json:"starts_at"`
+	EndsAt    time.Time         `json:"ends_at"`
+	Duration  string            `json:"duration"`
+	Comment   string            `json:"comment"`
+	CreatedBy string            `json:"created_by"`
+}
+
+// Handler returns the HTTP handler of the silences API, served under /api/v1/silences. Requests have to
+// carry token as a bearer token. An empty token leaves the API unauthenticated.
+func (m *Manager) Handler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/silences", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, m.Silences())
+	})
+	mux.HandleFunc("POST /api/v1/silences", m.createSilence)
+	mux.HandleFunc("GET /api/v1/silences/{id}", func(w http.ResponseWriter, r *http.Request) {
+		silence, ok := m.Silence(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusNotFound, fmt.Errorf("silence not found"))
+			return
+		}
+		writeJSON(w, http.StatusOK, silence)
+	})
+	mux.HandleFunc("DELETE /api/v1/silences/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !m.DeleteSilence(r.PathValue("id")) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("silence not found"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		expected := "Bearer " + token
+		if token != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) != 1 {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid or missing bearer token"))
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// createSilence handles the silence creation requests.
+func (m *Manager) createSilence(w http.ResponseWriter, r *http.Request) {
+	var req silenceRequest
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err == nil {
+		err = json.Unmarshal(body, &req)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid silence: %v", err))
+		return
+	}
+
+	silence := Silence{
+		Checks:    req.Checks,
+		Selector:  req.Selector,
+		StartsAt:  req.StartsAt,
+		EndsAt:    req.EndsAt,
+		Comment:   req.Comment,
+		CreatedBy: req.CreatedBy,
+	}
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = time.Now()
+	}
+	if req.Duration != "" {
+		duration, err := config.ParseDuration(req.Duration)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		silence.EndsAt = silence.StartsAt.Add(duration)
+	}
+
+	silence, err = m.AddSilence(silence)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, silence)
+}
+
+// writeJSON writes value as the JSON response body.
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// writeError writes err as the JSON response body.
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
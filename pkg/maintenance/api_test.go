@@ -0,0 +1,170 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package maintenance
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// apiClient sends the requests of the tests to the silences API.
+type apiClient struct {
+	t       *testing.T
+	handler http.Handler
+	token   string
+}
+
+// do sends a request and returns the response status, decoding the body into result if set.
+func (c apiClient) do(method, path, body string, result interface{}) int {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	rec := httptest.NewRecorder()
+	c.handler.ServeHTTP(rec, req)
+
+	if result != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), result); err != nil {
+			c.t.Fatalf("%s %s: invalid response %q: %v", method, path, rec.Body.String(), err)
+		}
+	}
+
+	return rec.Code
+}
+
+// newTestManager returns a manager persisting the silences in stateDir.
+func newTestManager(t *testing.T, stateDir string) *Manager {
+	m, err := NewManager(nil, stateDir, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return m
+}
+
+func TestSilencesAPIAuth(t *testing.T) {
+	handler := newTestManager(t, "").Handler("secret")
+	for _, token := range []string{"", "wrong"} {
+		if status := (apiClient{t, handler, token}).do("GET", "/api/v1/silences", "", nil); status != http.StatusUnauthorized {
+			t.Errorf("token %q: status = %d, want %d", token, status, http.StatusUnauthorized)
+		}
+	}
+	if status := (apiClient{t, handler, "secret"}).do("GET", "/api/v1/silences", "", nil); status != http.StatusOK {
+		t.Errorf("status = %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestSilencesAPI(t *testing.T) {
+	stateDir := t.TempDir()
+	m := newTestManager(t, stateDir)
+	client := apiClient{t, m.Handler("secret"), "secret"}
+	labels := map[string]string{"check": "quay", "team": "release"}
+
+	var created Silence
+	status := client.do("POST", "/api/v1/silences",
+		`{"selector": {"team": "release"}, "duration": "2h", "comment": "proxy migration"}`, &created)
+	if status != http.StatusCreated || created.ID == "" {
+		t.Fatalf("create: status = %d, silence = %+v", status, created)
+	}
+	if d := created.EndsAt.Sub(created.StartsAt); d != 2*time.Hour {
+		t.Errorf("silence lasts %s, want 2h", d)
+	}
+	if !m.Active(labels, time.Now()) {
+		t.Error("silenced check not in maintenance")
+	}
+	if m.Active(map[string]string{"check": "git", "team": "build"}, time.Now()) {
+		t.Error("check outside of the selector silenced")
+	}
+
+	var listed []Silence
+	if status := client.do("GET", "/api/v1/silences", "", &listed); status != http.StatusOK || len(listed) != 1 {
+		t.Errorf("list: status = %d, silences = %+v", status, listed)
+	}
+	var fetched Silence
+	if status := client.do("GET", "/api/v1/silences/"+created.ID, "", &fetched); status != http.StatusOK ||
+		fetched.Comment != "proxy migration" {
+		t.Errorf("get: status = %d, silence = %+v", status, fetched)
+	}
+
+	// the silences are persisted in the state directory
+	if !newTestManager(t, stateDir).Active(labels, time.Now()) {
+		t.Error("silence not restored from the state directory")
+	}
+
+	if status := client.do("DELETE", "/api/v1/silences/"+created.ID, "", nil); status != http.StatusNoContent {
+		t.Errorf("delete: status = %d, want %d", status, http.StatusNoContent)
+	}
+	if status := client.do("DELETE", "/api/v1/silences/"+created.ID, "", nil); status != http.StatusNotFound {
+		t.Errorf("delete again: status = %d, want %d", status, http.StatusNotFound)
+	}
+	if m.Active(labels, time.Now()) {
+		t.Error("check still silenced after the silence was deleted")
+	}
+	if newTestManager(t, stateDir).Active(labels, time.Now()) {
+		t.Error("deleted silence restored from the state directory")
+	}
+}
+
+func TestSilencesAPIExpire(t *testing.T) {
+	m := newTestManager(t, t.TempDir())
+	client := apiClient{t, m.Handler(""), ""}
+
+	now := time.Now().UTC()
+	body, err := json.Marshal(map[string]interface{}{
+		"checks":    []string{"quay"},
+		"starts_at": now.Add(-2 * time.Hour),
+		"ends_at":   now.Add(-time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expired Silence
+	if status := client.do("POST", "/api/v1/silences", string(body), &expired); status != http.StatusCreated {
+		t.Fatalf("create: status = %d", status)
+	}
+
+	if m.Active(map[string]string{"check": "quay"}, now) {
+		t.Error("check silenced by an expired silence")
+	}
+	if status := client.do("GET", "/api/v1/silences/"+expired.ID, "", nil); status != http.StatusNotFound {
+		t.Errorf("get: status = %d, want %d", status, http.StatusNotFound)
+	}
+	var listed []Silence
+	if client.do("GET", "/api/v1/silences", "", &listed); len(listed) != 0 {
+		t.Errorf("expired silences listed: %+v", listed)
+	}
+}
+
+func TestSilencesAPIInvalid(t *testing.T) {
+	client := apiClient{t, newTestManager(t, "").Handler(""), ""}
+	for _, body := range []string{
+		`not json`,
+		`{"duration": "2h"}`,
+		`{"checks": ["quay"]}`,
+		`{"checks": ["quay"], "duration": "2 hours"}`,
+		`{"checks": ["quay"], "duration": "-1h"}`,
+	} {
+		if status := client.do("POST", "/api/v1/silences", body, nil); status != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", body, status, http.StatusBadRequest)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package maintenance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// schedule is a parsed cron expression: minute, hour, day of month, month and day of week
+type schedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny are set when the day fields start with *, as a day matches either field when
+	// both are restricted
+	domAny, dowAny bool
+}
+
+// cronField holds the bounds of a cron field
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// parseCron parses a standard 5 fields cron expression. Fields accept *, values, ranges, steps and
+// lists, like */15 or 1-5. Sunday is 0 or 7 in the day of week.
+func parseCron(expr string) (*schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("invalid cron expression %q: expected 5 fields", expr)
+	}
+
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		var err error
+		bits[i], err = parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %q: %v", expr, err)
+		}
+	}
+	// Sunday is both 0 and 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &schedule{
+		minute: bits[0],
+		hour:   bits[1],
+		dom:    bits[2],
+		month:  bits[3],
+		dow:    bits[4],
+		domAny: strings.HasPrefix(fields[2], "*"),
+		dowAny: strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// parseCronField returns the bitset of the values matched by a cron field.
+func parseCronField(field string, bounds cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepValue, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			step, err = strconv.Atoi(stepValue)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %s field: %s", bounds.name, part)
+			}
+		}
+
+		low, high := bounds.min, bounds.max
+		if rng != "*" {
+			from, to, isRange := strings.Cut(rng, "-")
+			var err error
+			if low, err = strconv.Atoi(from); err != nil {
+				return 0, fmt.Errorf("invalid %s field: %s", bounds.name, part)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(to); err != nil {
+					return 0, fmt.Errorf("invalid %s field: %s", bounds.name, part)
+				}
+			} else if hasStep {
+				high = bounds.max
+			}
+		}
+		if low < bounds.min || high > bounds.max || low > high {
+			return 0, fmt.Errorf("%s field out of range: %s", bounds.name, part)
+		}
+
+		for value := low; value <= high; value += step {
+			bits |= 1 << value
+		}
+	}
+
+	return bits, nil
+}
+
+// matches tells whether the schedule fires at the minute of t.
+func (s *schedule) matches(t time.Time) bool {
+	return s.minute&(1<<t.Minute()) != 0 && s.hour&(1<<t.Hour()) != 0 && s.month&(1<<int(t.Month())) != 0 &&
+		s.matchesDay(t)
+}
+
+// matchesDay tells whether the schedule fires on the day of t. A day matches either day field when both
+// are restricted.
+func (s *schedule) matchesDay(t time.Time) bool {
+	domMatch := s.dom&(1<<t.Day()) != 0
+	dowMatch := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+// prev returns the latest time the schedule fires at, not after t and after the given time. Whole months,
+// days and hours that don't match are skipped at once.
+func (s *schedule) prev(t, after time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute)
+	for t.After(after) {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = minuteBefore(t, time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()))
+		case !s.matchesDay(t):
+			t = minuteBefore(t, time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()))
+		case s.hour&(1<<t.Hour()) == 0:
+			// the minutes are subtracted, as the wall clock hour is ambiguous when DST ends
+			t = t.Add(-time.Duration(t.Minute()) * time.Minute).Add(-time.Minute)
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(-time.Minute)
+		default:
+			return t, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// minuteBefore returns the minute before start, the beginning of the month or day of t, so that prev skips
+// that whole month or day. When start is not before t, which happens when the midnight of start doesn't
+// exist or is ambiguous because of DST, the minute before the hour of t is returned instead.
+func minuteBefore(t, start time.Time) time.Time {
+	if !start.Before(t) {
+		start = t.Add(-time.Duration(t.Minute()) * time.Minute)
+	}
+
+	return start.Add(-time.Minute)
+}
+
+// activeSince returns the start of the latest window of the given duration which contains now, if any.
+func (s *schedule) activeSince(now time.Time, duration time.Duration) (time.Time, bool) {
+	return s.prev(now, now.Add(-duration))
+}
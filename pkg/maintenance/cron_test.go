@@ -0,0 +1,203 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package maintenance
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// date returns the given UTC time.
+func date(year int, month time.Month, day, hour, minute int) time.Time {
+	return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	for _, expr := range []string{
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q): expected an error", expr)
+		}
+	}
+}
+
+func TestScheduleMatches(t *testing.T) {
+	// 2026-06-01 is a Monday
+	tests := []struct {
+		expr string
+		time time.Time
+		want bool
+	}{
+		{"*/15 * * * *", date(2026, 6, 1, 10, 30), true},
+		{"*/15 * * * *", date(2026, 6, 1, 10, 45), true},
+		{"*/15 * * * *", date(2026, 6, 1, 10, 31), false},
+		{"10-20/5 * * * *", date(2026, 6, 1, 10, 15), true},
+		{"10-20/5 * * * *", date(2026, 6, 1, 10, 25), false},
+		{"0 9 * * 1-5", date(2026, 6, 1, 9, 0), true},
+		{"0 9 * * 1-5", date(2026, 6, 5, 9, 0), true},
+		{"0 9 * * 1-5", date(2026, 6, 6, 9, 0), false},
+		{"0 9 * * 1-5", date(2026, 6, 1, 10, 0), false},
+		{"0 0 * * 7", date(2026, 6, 7, 0, 0), true},
+		{"0 0 * * 0", date(2026, 6, 7, 0, 0), true},
+		{"0 0 * * 7", date(2026, 6, 6, 0, 0), false},
+		{"0 0 * * 5-7", date(2026, 6, 7, 0, 0), true},
+		{"0 0 1,15 * *", date(2026, 6, 15, 0, 0), true},
+		{"0 0 * 6 *", date(2026, 7, 1, 0, 0), false},
+		// both day fields restricted: either matches
+		{"0 0 1 * 1", date(2026, 7, 1, 0, 0), true},
+		{"0 0 1 * 1", date(2026, 6, 8, 0, 0), true},
+		{"0 0 1 * 1", date(2026, 6, 9, 0, 0), false},
+		// one day field unrestricted: the other has to match
+		{"0 0 1 * *", date(2026, 6, 8, 0, 0), false},
+		{"0 0 * * 1", date(2026, 7, 1, 0, 0), false},
+		// a day field starting with * counts as unrestricted, like in cron
+		{"0 0 */2 * 1", date(2026, 6, 1, 0, 0), true},
+		{"0 0 */2 * 1", date(2026, 6, 3, 0, 0), false},
+		{"0 0 */2 * 1", date(2026, 6, 8, 0, 0), false},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.matches(tt.time); got != tt.want {
+			t.Errorf("%q matches %s = %v, want %v", tt.expr, tt.time.Format("Mon 2006-01-02 15:04"), got, tt.want)
+		}
+	}
+}
+
+// scanActiveSince looks for the start of the window minute by minute.
+func scanActiveSince(s *schedule, now time.Time, duration time.Duration) (time.Time, bool) {
+	for start := now.Truncate(time.Minute); start.After(now.Add(-duration)); start = start.Add(-time.Minute) {
+		if s.matches(start) {
+			return start, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func TestActiveSinceMatchesScan(t *testing.T) {
+	exprs := []string{"*/15 * * * *", "30 2 * * *", "0 9 * * 1-5", "0 0 1 * 1", "0 22 * * 7", "0 0 29 2 *",
+		"45 23 31 * *"}
+	locations := []string{"UTC", "Europe/Prague", "America/New_York", "Australia/Lord_Howe"}
+	durations := []time.Duration{time.Minute, 90 * time.Minute, 25 * time.Hour, 8 * 24 * time.Hour}
+
+	random := rand.New(rand.NewSource(1))
+	for _, name := range locations {
+		location, err := time.LoadLocation(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, expr := range exprs {
+			s, err := parseCron(expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 40; i++ {
+				now := date(2026, 1, 1, 0, 0).Add(time.Duration(random.Int63n(int64(365 * 24 * time.Hour)))).In(location)
+				duration := durations[i%len(durations)]
+				got, gotOk := s.activeSince(now, duration)
+				want, wantOk := scanActiveSince(s, now, duration)
+				if !got.Equal(want) || gotOk != wantOk {
+					t.Errorf("%q in %s at %s for %s: activeSince = %s, %v, want %s, %v", expr, name, now, duration,
+						got, gotOk, want, wantOk)
+				}
+			}
+		}
+	}
+}
+
+func TestActiveSinceDST(t *testing.T) {
+	prague, err := time.LoadLocation("Europe/Prague")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// DST ends on 2026-10-25 at 03:00 CEST, 02:00-02:59 happening twice, and starts on 2026-03-29 at 02:00
+	// CET, 02:00-02:59 being skipped
+	tests := []struct {
+		name      string
+		expr      string
+		duration  time.Duration
+		now       time.Time
+		wantStart time.Time
+		wantOk    bool
+	}{
+		{"first 02:30 when DST ends", "30 2 * * *", time.Hour,
+			date(2026, 10, 25, 1, 15), date(2026, 10, 25, 0, 30), true},
+		{"second 02:30 when DST ends", "30 2 * * *", time.Hour,
+			date(2026, 10, 25, 1, 45), date(2026, 10, 25, 1, 30), true},
+		{"no 02:30 when DST starts", "30 2 * * *", time.Hour,
+			date(2026, 3, 29, 1, 15), time.Time{}, false},
+		{"window lasting across DST start", "0 1 * * *", 3 * time.Hour,
+			date(2026, 3, 29, 2, 30), date(2026, 3, 29, 0, 0), true},
+		{"window ended across DST start", "0 1 * * *", 2 * time.Hour,
+			date(2026, 3, 29, 2, 30), time.Time{}, false},
+	}
+	for _, tt := range tests {
+		s, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start, ok := s.activeSince(tt.now.In(prague), tt.duration)
+		if ok != tt.wantOk || !start.Equal(tt.wantStart) {
+			t.Errorf("%s: activeSince = %s, %v, want %s, %v", tt.name, start.UTC(), ok, tt.wantStart, tt.wantOk)
+		}
+	}
+}
+
+func TestActiveSinceLongWindow(t *testing.T) {
+	s, err := parseCron("0 0 1 1 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, ok := s.activeSince(date(2026, 1, 20, 12, 0), 30*24*time.Hour)
+	if !ok || !start.Equal(date(2026, 1, 1, 0, 0)) {
+		t.Errorf("activeSince = %s, %v, want 2026-01-01 00:00", start, ok)
+	}
+	if _, ok := s.activeSince(date(2026, 3, 1, 0, 0), 30*24*time.Hour); ok {
+		t.Error("window still active after 30 days")
+	}
+}
+
+func TestWindowActiveTimezone(t *testing.T) {
+	window := Window{Name: "nightly", Checks: []string{"quay"}, Cron: "0 2 * * *", Duration: time.Hour,
+		Timezone: "Europe/Prague"}
+	if err := window.parse(); err != nil {
+		t.Fatal(err)
+	}
+	// 02:30 CEST
+	if !window.active(date(2026, 6, 1, 0, 30)) {
+		t.Error("window not active at 02:30 Europe/Prague")
+	}
+	// 02:30 UTC
+	if window.active(date(2026, 6, 1, 2, 30)) {
+		t.Error("window active at 02:30 UTC")
+	}
+}
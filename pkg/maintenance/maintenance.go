@@ -0,0 +1,309 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package maintenance
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// silencesFile is the file the silences are persisted to in the state directory
+const silencesFile = "silences.json"
+
+// Window is a scheduled maintenance window. It either starts at every time matched by Cron and lasts
+// Duration, or lasts from Start to End.
+type Window struct {
+	Name string
+	// Checks are the names of the checks in maintenance
+	Checks []string
+	// Selector selects the checks in maintenance by their labels
+	Selector map[string]string
+	Cron     string
+	Duration time.Duration
+	// Timezone is the location Cron is evaluated in. Defaults to UTC.
+	Timezone string
+	Start    time.Time
+	End      time.Time
+
+	schedule *schedule
+	location *time.Location
+}
+
+// Silence is an ad-hoc maintenance window created through the API
+type Silence struct {
+	ID        string            `json:"id"`
+	Checks    []string          `json:"checks,omitempty"`
+	Selector  map[string]string `json:"selector,omitempty"`
+	StartsAt  time.Time         `json:"starts_at"`
+	EndsAt    time.Time         `json:"ends_at"`
+	Comment   string            `json:"comment,omitempty"`
+	CreatedBy string            `json:"created_by,omitempty"`
+}
+
+// Manager tells whether the checks are in a maintenance window or silenced
+type Manager struct {
+	windows  []Window
+	stateDir string
+	log      *log.Logger
+
+	mu       sync.Mutex
+	silences map[string]Silence
+}
+
+// NewManager returns a new instance of Manager. The silences are persisted in stateDir, or only kept in
+// memory if stateDir is empty. An error is returned for invalid windows.
+func NewManager(windows []Window, stateDir string, log *log.Logger) (*Manager, error) {
+	for i := range windows {
+		if err := windows[i].parse(); err != nil {
+			return nil, fmt.Errorf("maintenance window %s: %v", windows[i].Name, err)
+		}
+	}
+
+	m := &Manager{
+		windows:  windows,
+		stateDir: stateDir,
+		log:      log,
+		silences: map[string]Silence{},
+	}
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// parse validates the window and parses its schedule.
+func (w *Window) parse() error {
+	if len(w.Checks) == 0 && len(w.Selector) == 0 {
+		return fmt.Errorf("no checks or selector")
+	}
+
+	if w.Cron == "" {
+		if w.Start.IsZero() || !w.End.After(w.Start) {
+			return fmt.Errorf("either a cron and a duration, or a start before an end are required")
+		}
+		return nil
+	}
+	if w.Duration <= 0 {
+		return fmt.Errorf("cron windows require a duration")
+	}
+
+	var err error
+	if w.schedule, err = parseCron(w.Cron); err != nil {
+		return err
+	}
+	w.location = time.UTC
+	if w.Timezone != "" {
+		if w.location, err = time.LoadLocation(w.Timezone); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// active tells whether the window is open at the given time.
+func (w *Window) active(now time.Time) bool {
+	if w.schedule == nil {
+		return !now.Before(w.Start) && now.Before(w.End)
+	}
+	_, ok := w.schedule.activeSince(now.In(w.location), w.Duration)
+
+	return ok
+}
+
+// selects tells whether the checks and the selector match the check with the given labels.
+func selects(checks []string, selector map[string]string, labels map[string]string) bool {
+	if len(checks) != 0 && !slices.Contains(checks, labels["check"]) {
+		return false
+	}
+	for name, value := range selector {
+		if labels[name] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Active returns true when the check with the given labels is in an open maintenance window or silenced
+// at the given time.
+func (m *Manager) Active(labels map[string]string, now time.Time) bool {
+	for i := range m.windows {
+		if selects(m.windows[i].Checks, m.windows[i].Selector, labels) && m.windows[i].active(now) {
+			return true
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, silence := range m.silences {
+		if selects(silence.Checks, silence.Selector, labels) && !now.Before(silence.StartsAt) &&
+			now.Before(silence.EndsAt) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AddSilence stores a new silence and returns it with its ID set. An error is returned for invalid
+// silences.
+func (m *Manager) AddSilence(silence Silence) (Silence, error) {
+	if len(silence.Checks) == 0 && len(silence.Selector) == 0 {
+		return silence, fmt.Errorf("no checks or selector")
+	}
+	if silence.StartsAt.IsZero() {
+		silence.StartsAt = time.Now()
+	}
+	if !silence.EndsAt.After(silence.StartsAt) {
+		return silence, fmt.Errorf("the silence has to end after it starts")
+	}
+
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return silence, err
+	}
+	silence.ID = hex.EncodeToString(id)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.silences[silence.ID] = silence
+	m.log.Printf("silence %s added until %s\n", silence.ID, silence.EndsAt.Format(time.RFC3339))
+	m.save()
+
+	return silence, nil
+}
+
+// Silences returns the silences which didn't expire yet, ordered by end time.
+func (m *Manager) Silences() []Silence {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire()
+
+	silences := []Silence{}
+	for _, silence := range m.silences {
+		silences = append(silences, silence)
+	}
+	slices.SortFunc(silences, func(a, b Silence) int {
+		return a.EndsAt.Compare(b.EndsAt)
+	})
+
+	return silences
+}
+
+// Silence returns the silence with the given ID, if it didn't expire yet.
+func (m *Manager) Silence(id string) (Silence, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.expire()
+	silence, ok := m.silences[id]
+
+	return silence, ok
+}
+
+// DeleteSilence deletes a silence. False is returned if it doesn't exist.
+func (m *Manager) DeleteSilence(id string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.silences[id]; !ok {
+		return false
+	}
+	delete(m.silences, id)
+	m.log.Printf("silence %s deleted\n", id)
+	m.save()
+
+	return true
+}
+
+// expire drops the expired silences, the caller holding the lock.
+func (m *Manager) expire() {
+	now := time.Now()
+	for id, silence := range m.silences {
+		if !now.Before(silence.EndsAt) {
+			delete(m.silences, id)
+		}
+	}
+}
+
+// load reads the persisted silences.
+func (m *Manager) load() error {
+	if m.stateDir == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(m.stateDir, silencesFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &m.silences); err != nil {
+		return fmt.Errorf("invalid silences in %s: %v", m.stateDir, err)
+	}
+	m.expire()
+
+	return nil
+}
+
+// save persists the silences, the caller holding the lock. Failures are logged, the silences being
+// still kept in memory.
+func (m *Manager) save() {
+	if m.stateDir == "" {
+		return
+	}
+
+	m.expire()
+	if err := m.write(); err != nil {
+		m.log.Printf("[ERROR] failed to save the silences: %v\n", err)
+	}
+}
+
+// write writes the silences to the state directory. The file is replaced atomically.
+func (m *Manager) write() error {
+	data, err := json.Marshal(m.silences)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.stateDir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(m.stateDir, ".silences-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(m.stateDir, silencesFile))
+}
@@ -18,13 +18,80 @@ package metrics
 import (
 	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
-// CompositeMetric holds instances of GaugeMetric and HistogramMetric
+const (
+	// RESULT_SUCCESS is the result label of successful check runs
+	RESULT_SUCCESS = "success"
+	// RESULT_FAILURE is the result label of failed check runs
+	RESULT_FAILURE = "failure"
+	// RESULT_SKIPPED is the result label of check runs skipped because of a failing dependency
+	RESULT_SKIPPED = "skipped"
+)
+
+// CheckLabels are the labels identifying a check, set on every series
+var CheckLabels = []string{"check", "type", "target"}
+
+// reservedLabels are the label names set by the service
+var reservedLabels = []string{"check", "type", "target", "maintenance", "result", "reason", "path", "sha", "window"}
+
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// CompositeMetric holds the metric families recorded by every check
 type CompositeMetric struct {
-	Gauge     GaugeMetric
-	Histogram HistogramMetric
+	// Up is 1 when the last run of the check succeeded, 0 otherwise
+	Up GaugeMetric
+	// Runs counts the check runs by result
+	Runs CounterMetric
+	// Failures counts the failed check runs by reason
+	Failures CounterMetric
+	// LastRun is the time of the last check run
+	LastRun GaugeMetric
+	// LastSuccess is the time of the last successful check run
+	LastSuccess GaugeMetric
+	// ConsecutiveFailures is the number of failed runs since the last successful one
+	ConsecutiveFailures GaugeMetric
+	// Skipped is 1 when the last run of the check was skipped, 0 otherwise
+	Skipped GaugeMetric
+	// StaticLabels are the names of the user defined labels set on every series
+	StaticLabels []string
+	// SLO computes the availability of the checks with an objective, if set
+	SLO *SLOMetric
+	// Maintenance tells whether the checks are in maintenance, if set
+	Maintenance Maintenance
+
+	states *checkStates
+}
+
+// Maintenance tells whether a check is in a maintenance window or silenced
+type Maintenance interface {
+	// Active returns true when the check with the given labels is in maintenance at the given time
+	Active(labels map[string]string, now time.Time) bool
+}
+
+// checkState is the last state recorded for a check, which is exported again under the new maintenance
+// label value when the check enters or leaves maintenance
+type checkState struct {
+	published           bool
+	maintenance         bool
+	up                  float64
+	lastRun             float64
+	lastSuccess         float64
+	consecutiveFailures float64
+	skipped             float64
+}
+
+// checkStates holds the state of every check
+type checkStates struct {
+	mu     sync.Mutex
+	states map[string]*checkState
 }
 
 // GaugeMetric
@@ -34,23 +101,209 @@ type GaugeMetric struct {
 	Metric *prometheus.GaugeVec
 }
 
-// HistogramMetric
-type HistogramMetric struct {
+// GitMetric holds the metrics specific to the git checks
+type GitMetric struct {
+	Path          GaugeMetric
+	HeadTimestamp GaugeMetric
+	HeadInfo      GaugeMetric
+	HeadChanges   CounterMetric
+	// RateLimitRemaining is the provider API rate limit headroom
+	RateLimitRemaining GaugeMetric
+	// CacheSize is the size of the on-disk repository cache
+	CacheSize GaugeMetric
+}
+
+// CounterMetric
+type CounterMetric struct {
 	Prefix string
 	Labels []string
-	Metric *prometheus.HistogramVec
+	Metric *prometheus.CounterVec
+}
+
+// NewCompositeMetric creates a new instance of CompositeMetric. The series are labeled with the
+// CheckLabels followed by the staticLabels and the maintenance label.
+func NewCompositeMetric(prefix string, staticLabels []string) CompositeMetric {
+	labels := append(append([]string{}, CheckLabels...), staticLabels...)
+	labels = append(labels, "maintenance")
+
+	return CompositeMetric{
+		Up:                  NewNamedGaugeMetric(prefix, "check_up", labels),
+		Runs:                NewNamedCounterMetric(prefix, "check_runs_total", withLabel(labels, "result")),
+		Failures:            NewNamedCounterMetric(prefix, "check_failures_total", withLabel(labels, "reason")),
+		LastRun:             NewNamedGaugeMetric(prefix, "check_last_run_timestamp_seconds", labels),
+		LastSuccess:         NewNamedGaugeMetric(prefix, "check_last_success_timestamp_seconds", labels),
+		ConsecutiveFailures: NewNamedGaugeMetric(prefix, "check_consecutive_failures", labels),
+		Skipped:             NewNamedGaugeMetric(prefix, "check_skipped", labels),
+		StaticLabels:        staticLabels,
+		states:              &checkStates{states: map[string]*checkState{}},
+	}
+}
+
+// Skip records a check run skipped because of a failing dependency. The Up series is dropped until the
+// check runs again, so that a skipped check shows neither as up nor as down, the other families keep the
+// result of the last run, and the SLO ignores it.
+func (cm *CompositeMetric) Skip(metadata []string) {
+	cm.states.mu.Lock()
+	defer cm.states.mu.Unlock()
+
+	maintenance := cm.inMaintenance(metadata)
+	state := cm.states.get(metadata[0])
+	state.skipped = 1
+	cm.publish(metadata, state, maintenance)
+	cm.Runs.Record(withLabel(metadata, strconv.FormatBool(maintenance), RESULT_SKIPPED), 1)
+}
+
+// LabelNames returns the names of the labels of a check: the CheckLabels followed by the static labels
+func (cm *CompositeMetric) LabelNames() []string {
+	return append(append([]string{}, CheckLabels...), cm.StaticLabels...)
+}
+
+// LabelValues returns the label values of a check: its name, type and target followed by its static
+// labels. Static labels the check doesn't set are left empty, so that all the checks share the same
+// label set.
+func (cm *CompositeMetric) LabelValues(check, checkType, target string, staticLabels map[string]string) []string {
+	values := []string{check, checkType, target}
+	for _, name := range cm.StaticLabels {
+		values = append(values, staticLabels[name])
+	}
+
+	return values
+}
+
+// StaticLabelNames returns the sorted union of the static label names of the checks. Invalid names and
+// the names of the labels set by the service are rejected.
+func StaticLabelNames(checkLabels []map[string]string) ([]string, error) {
+	names := []string{}
+	for _, labels := range checkLabels {
+		for name := range labels {
+			if !labelNameRe.MatchString(name) || strings.HasPrefix(name, "__") {
+				return nil, fmt.Errorf("invalid label name: %s", name)
+			}
+			if slices.Contains(reservedLabels, name) {
+				return nil, fmt.Errorf("reserved label name: %s", name)
+			}
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Collectors returns the prometheus collectors of a CompositeMetric
+func (cm *CompositeMetric) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		cm.Up.Metric,
+		cm.Runs.Metric,
+		cm.Failures.Metric,
+		cm.LastRun.Metric,
+		cm.LastSuccess.Metric,
+		cm.ConsecutiveFailures.Metric,
+		cm.Skipped.Metric,
+	}
+}
+
+// Record records a check run in all the families. The metadata holds the check label values, a code of
+// 0 is a success and reason is the failure reason. Runs in maintenance are labeled with
+// maintenance="true" and left out of the SLO.
+func (cm *CompositeMetric) Record(metadata []string, code float64, reason string) {
+	cm.states.mu.Lock()
+	defer cm.states.mu.Unlock()
+
+	now := float64(time.Now().Unix())
+	maintenance := cm.inMaintenance(metadata)
+	if cm.SLO != nil && !maintenance {
+		cm.SLO.Record(metadata, code == 0)
+	}
+
+	state := cm.states.get(metadata[0])
+	state.up = FlipValue(code)
+	state.lastRun = now
+	state.skipped = 0
+	if code == 0 {
+		state.lastSuccess = now
+		state.consecutiveFailures = 0
+	} else {
+		state.consecutiveFailures++
+	}
+	cm.publish(metadata, state, maintenance)
+
+	series := withLabel(metadata, strconv.FormatBool(maintenance))
+	if code == 0 {
+		cm.Runs.Record(withLabel(series, RESULT_SUCCESS), 1)
+		return
+	}
+	cm.Runs.Record(withLabel(series, RESULT_FAILURE), 1)
+	cm.Failures.Record(withLabel(series, reason), 1)
+}
+
+// publish exports the state of a check in the gauges. The gauges exported under the other maintenance
+// label value are deleted when the check enters or leaves maintenance, and Up while the check is skipped.
+func (cm *CompositeMetric) publish(metadata []string, state *checkState, maintenance bool) {
+	gauges := []GaugeMetric{cm.Up, cm.LastRun, cm.LastSuccess, cm.ConsecutiveFailures, cm.Skipped}
+	if state.published && state.maintenance != maintenance {
+		previous := withLabel(metadata, strconv.FormatBool(state.maintenance))
+		for _, gauge := range gauges {
+			gauge.Metric.DeleteLabelValues(previous...)
+		}
+	}
+	state.published = true
+	state.maintenance = maintenance
+
+	series := withLabel(metadata, strconv.FormatBool(maintenance))
+	if state.skipped != 0 {
+		cm.Up.Metric.DeleteLabelValues(series...)
+	}
+	if state.lastRun != 0 {
+		if state.skipped == 0 {
+			cm.Up.Record(series, state.up)
+		}
+		cm.LastRun.Record(series, state.lastRun)
+		cm.ConsecutiveFailures.Record(series, state.consecutiveFailures)
+	}
+	if state.lastSuccess != 0 {
+		cm.LastSuccess.Record(series, state.lastSuccess)
+	}
+	cm.Skipped.Record(series, state.skipped)
 }
 
-// NewGaugeMetric creates a new instance of GaugeMetric
-func NewGaugeMetric(prefix string, labels []string) GaugeMetric {
+// inMaintenance tells whether the check with the given label values is in maintenance.
+func (cm *CompositeMetric) inMaintenance(metadata []string) bool {
+	if cm.Maintenance == nil {
+		return false
+	}
+
+	labels := map[string]string{}
+	for i, name := range cm.LabelNames() {
+		labels[name] = metadata[i]
+	}
+
+	return cm.Maintenance.Active(labels, time.Now())
+}
+
+// get returns the state of a check, the caller holding the lock.
+func (cs *checkStates) get(check string) *checkState {
+	state, ok := cs.states[check]
+	if !ok {
+		state = &checkState{}
+		cs.states[check] = state
+	}
+
+	return state
+}
+
+// NewNamedGaugeMetric creates a new instance of GaugeMetric named <prefix>_<name>
+func NewNamedGaugeMetric(prefix string, name string, labels []string) GaugeMetric {
 	newGaugeMetric := GaugeMetric{
 		Prefix: prefix,
 		Labels: labels,
 	}
 
 	opts := prometheus.GaugeOpts{
-		Name: fmt.Sprintf("%s_check_gauge", strings.ToLower(prefix)),
-		Help: fmt.Sprintf("%s check_gauge", prefix),
+		Name: fmt.Sprintf("%s_%s", strings.ToLower(prefix), name),
+		Help: fmt.Sprintf("%s %s", prefix, name),
 	}
 	newGauge := prometheus.NewGaugeVec(opts, labels)
 	newGaugeMetric.Metric = newGauge
@@ -58,20 +311,46 @@ func NewGaugeMetric(prefix string, labels []string) GaugeMetric {
 	return newGaugeMetric
 }
 
-// NewHistogramMetric create a new instance of HistogramMetric
-func NewHistogramMetric(prefix string, labels []string) HistogramMetric {
-	newHistogramMetric := HistogramMetric{
+// NewGitMetric creates a new instance of GitMetric, labeled like the CompositeMetric
+func NewGitMetric(prefix string, staticLabels []string) GitMetric {
+	labels := append(append([]string{}, CheckLabels...), staticLabels...)
+
+	return GitMetric{
+		Path:               NewNamedGaugeMetric(prefix, "git_path_gauge", withLabel(labels, "path")),
+		HeadTimestamp:      NewNamedGaugeMetric(prefix, "git_head_commit_timestamp_seconds", labels),
+		HeadInfo:           NewNamedGaugeMetric(prefix, "git_head_commit_info", withLabel(labels, "sha")),
+		HeadChanges:        NewNamedCounterMetric(prefix, "git_head_changes_total", labels),
+		RateLimitRemaining: NewNamedGaugeMetric(prefix, "git_api_ratelimit_remaining", labels),
+		CacheSize:          NewNamedGaugeMetric(prefix, "git_cache_size_bytes", labels),
+	}
+}
+
+// Collectors returns the prometheus collectors of a GitMetric
+func (gm *GitMetric) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		gm.Path.Metric,
+		gm.HeadTimestamp.Metric,
+		gm.HeadInfo.Metric,
+		gm.HeadChanges.Metric,
+		gm.RateLimitRemaining.Metric,
+		gm.CacheSize.Metric,
+	}
+}
+
+// NewNamedCounterMetric creates a new instance of CounterMetric named <prefix>_<name>
+func NewNamedCounterMetric(prefix string, name string, labels []string) CounterMetric {
+	newCounterMetric := CounterMetric{
 		Prefix: prefix,
 		Labels: labels,
 	}
-	opts := prometheus.HistogramOpts{
-		Name: fmt.Sprintf("%s_check_histogram", strings.ToLower(prefix)),
-		Help: fmt.Sprintf("%s check_histogram", prefix),
+
+	opts := prometheus.CounterOpts{
+		Name: fmt.Sprintf("%s_%s", strings.ToLower(prefix), name),
+		Help: fmt.Sprintf("%s %s", prefix, name),
 	}
-	newHistogram := prometheus.NewHistogramVec(opts, labels)
-	newHistogramMetric.Metric = newHistogram
+	newCounterMetric.Metric = prometheus.NewCounterVec(opts, labels)
 
-	return newHistogramMetric
+	return newCounterMetric
 }
 
 // Record records a new value for a GaugeMetric
@@ -84,14 +363,19 @@ func (gm *GaugeMetric) Record(metadata []string, value float64) {
 	gm.Metric.With(prometheus.Labels(labels)).Set(value)
 }
 
-// Record records a new value for a HistogramMetric
-func (hm *HistogramMetric) Record(metadata []string, value float64) {
+// Record adds a value to a CounterMetric
+func (cm *CounterMetric) Record(metadata []string, value float64) {
 	// building labels
 	labels := map[string]string{}
-	for k, v := range hm.Labels {
+	for k, v := range cm.Labels {
 		labels[v] = metadata[k]
 	}
-	hm.Metric.With(prometheus.Labels(labels)).Observe(value)
+	cm.Metric.With(prometheus.Labels(labels)).Add(value)
+}
+
+// withLabel returns a copy of metadata with the values of extra labels appended
+func withLabel(metadata []string, values ...string) []string {
+	return append(append([]string{}, metadata...), values...)
 }
 
 // FlipValue flips 0<->1
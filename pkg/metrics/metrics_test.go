@@ -0,0 +1,210 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSkipDropsUp(t *testing.T) {
+	cm := NewCompositeMetric("test", nil)
+	metadata := cm.LabelValues("github", "git", "github.com", nil)
+
+	cm.Record(metadata, 1, "timeout")
+	cm.Skip(metadata)
+	if err := testutil.CollectAndCompare(cm.Up.Metric, strings.NewReader("")); err != nil {
+		t.Errorf("check_up exported while skipped: %v", err)
+	}
+	if err := testutil.CollectAndCompare(cm.Skipped.Metric, strings.NewReader(`
+# HELP test_check_skipped test check_skipped
+# TYPE test_check_skipped gauge
+test_check_skipped{check="github",maintenance="false",target="github.com",type="git"} 1
+`)); err != nil {
+		t.Error(err)
+	}
+
+	cm.Record(metadata, 0, "")
+	if err := testutil.CollectAndCompare(cm.Up.Metric, strings.NewReader(`
+# HELP test_check_up test check_up
+# TYPE test_check_up gauge
+test_check_up{check="github",maintenance="false",target="github.com",type="git"} 1
+`)); err != nil {
+		t.Errorf("check_up not exported again once run: %v", err)
+	}
+}
+
+func TestRecordRunsAndFailures(t *testing.T) {
+	cm := NewCompositeMetric("test", nil)
+	metadata := cm.LabelValues("github", "git", "github.com", nil)
+
+	cm.Record(metadata, 1, "timeout")
+	cm.Record(metadata, 1, "timeout")
+	cm.Record(metadata, 1, "dns")
+	cm.Record(metadata, 0, "")
+	if err := testutil.CollectAndCompare(cm.Runs.Metric, strings.NewReader(`
+# HELP test_check_runs_total test check_runs_total
+# TYPE test_check_runs_total counter
+test_check_runs_total{check="github",maintenance="false",result="failure",target="github.com",type="git"} 3
+test_check_runs_total{check="github",maintenance="false",result="success",target="github.com",type="git"} 1
+`)); err != nil {
+		t.Error(err)
+	}
+	if err := testutil.CollectAndCompare(cm.Failures.Metric, strings.NewReader(`
+# HELP test_check_failures_total test check_failures_total
+# TYPE test_check_failures_total counter
+test_check_failures_total{check="github",maintenance="false",reason="dns",target="github.com",type="git"} 1
+test_check_failures_total{check="github",maintenance="false",reason="timeout",target="github.com",type="git"} 2
+`)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRecordConsecutiveFailures(t *testing.T) {
+	cm := NewCompositeMetric("test", nil)
+	metadata := cm.LabelValues("github", "git", "github.com", nil)
+	series := func(value string) *strings.Reader {
+		return strings.NewReader(`
+# HELP test_check_consecutive_failures test check_consecutive_failures
+# TYPE test_check_consecutive_failures gauge
+test_check_consecutive_failures{check="github",maintenance="false",target="github.com",type="git"} ` + value + "\n")
+	}
+
+	cm.Record(metadata, 1, "timeout")
+	cm.Record(metadata, 1, "timeout")
+	if err := testutil.CollectAndCompare(cm.ConsecutiveFailures.Metric, series("2")); err != nil {
+		t.Error(err)
+	}
+	cm.Record(metadata, 0, "")
+	if err := testutil.CollectAndCompare(cm.ConsecutiveFailures.Metric, series("0")); err != nil {
+		t.Errorf("not reset on success: %v", err)
+	}
+	cm.Record(metadata, 1, "timeout")
+	if err := testutil.CollectAndCompare(cm.ConsecutiveFailures.Metric, series("1")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRecordLastSuccess(t *testing.T) {
+	cm := NewCompositeMetric("test", nil)
+	metadata := cm.LabelValues("github", "git", "github.com", nil)
+
+	cm.Record(metadata, 1, "timeout")
+	if err := testutil.CollectAndCompare(cm.LastSuccess.Metric, strings.NewReader("")); err != nil {
+		t.Errorf("last success exported before any success: %v", err)
+	}
+
+	// a success long ago, which a failure must not move
+	cm.states.get("github").lastSuccess = 1
+	cm.Record(metadata, 1, "timeout")
+	if err := testutil.CollectAndCompare(cm.LastSuccess.Metric, strings.NewReader(`
+# HELP test_check_last_success_timestamp_seconds test check_last_success_timestamp_seconds
+# TYPE test_check_last_success_timestamp_seconds gauge
+test_check_last_success_timestamp_seconds{check="github",maintenance="false",target="github.com",type="git"} 1
+`)); err != nil {
+		t.Errorf("last success moved on failure: %v", err)
+	}
+
+	cm.Record(metadata, 0, "")
+	if got := testutil.ToFloat64(cm.LastSuccess.Metric.WithLabelValues(append(metadata, "false")...)); got <= 1 {
+		t.Errorf("last success not moved on success, got %v", got)
+	}
+}
+
+func TestStaticLabelNames(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  []map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{"none", []map[string]string{nil, {}}, []string{}, false},
+		{"sorted union", []map[string]string{{"team": "a", "env": "prod"}, {"app": "b", "env": "stage"}},
+			[]string{"app", "env", "team"}, false},
+		{"invalid name", []map[string]string{{"1team": "a"}}, nil, true},
+		{"double underscore", []map[string]string{{"__team": "a"}}, nil, true},
+		{"reserved name", []map[string]string{{"target": "a"}}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// map iteration is random, the order must not depend on it
+			for i := 0; i < 10; i++ {
+				got, err := StaticLabelNames(tt.labels)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+				}
+				if !tt.wantErr && !slices.Equal(got, tt.want) {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestStaticLabelValues(t *testing.T) {
+	names, err := StaticLabelNames([]map[string]string{{"team": "a", "env": "prod"}, {"app": "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm := NewCompositeMetric("test", names)
+
+	metadata := cm.LabelValues("github", "git", "github.com", map[string]string{"team": "a", "env": "prod"})
+	if want := []string{"github", "git", "github.com", "", "prod", "a"}; !slices.Equal(metadata, want) {
+		t.Errorf("got the %v label values, want %v", metadata, want)
+	}
+	cm.Record(metadata, 0, "")
+	if err := testutil.CollectAndCompare(cm.Up.Metric, strings.NewReader(`
+# HELP test_check_up test check_up
+# TYPE test_check_up gauge
+test_check_up{app="",check="github",env="prod",maintenance="false",target="github.com",team="a",type="git"} 1
+`)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewRegistry(t *testing.T) {
+	// two registries in the same process, each with the runtime collectors and the check metrics
+	for _, prefix := range []string{"first", "second"} {
+		r := NewRegistry(prefix, true)
+		cm := NewCompositeMetric(prefix, nil)
+		if err := r.Register(cm.Collectors()...); err != nil {
+			t.Fatalf("%s registry: %v", prefix, err)
+		}
+
+		if count, err := testutil.GatherAndCount(r.Gatherer(), prefix+"_build_info"); err != nil || count != 1 {
+			t.Errorf("%s registry exports %d %s_build_info series (%v), want 1", prefix, count, prefix, err)
+		}
+		if count, err := testutil.GatherAndCount(r.Gatherer(), "go_goroutines"); err != nil || count != 1 {
+			t.Errorf("%s registry exports %d go_goroutines series (%v), want 1", prefix, count, err)
+		}
+		cm.Record(cm.LabelValues("github", "git", "github.com", nil), 0, "")
+		if err := testutil.GatherAndCompare(r.Gatherer(), strings.NewReader(`
+# HELP `+prefix+`_check_up `+prefix+` check_up
+# TYPE `+prefix+`_check_up gauge
+`+prefix+`_check_up{check="github",maintenance="false",target="github.com",type="git"} 1
+`), prefix+"_check_up"); err != nil {
+			t.Errorf("%s registry: %v", prefix, err)
+		}
+	}
+
+	r := NewRegistry("test", false)
+	if count, err := testutil.GatherAndCount(r.Gatherer(), "go_goroutines"); err != nil || count != 0 {
+		t.Errorf("exports %d go_goroutines series (%v) without the runtime collectors", count, err)
+	}
+}
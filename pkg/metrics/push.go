@@ -0,0 +1,115 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+const (
+	PUSH_MODE_PUSHGATEWAY  = "pushgateway"
+	PUSH_MODE_REMOTE_WRITE = "remote_write"
+)
+
+// PushOptions stores the settings of the push mode.
+type PushOptions struct {
+	// Mode is pushgateway, the default, or remote_write.
+	Mode string
+	// Job and Instance group the pushed series. They are the grouping key of the Pushgateway and are
+	// added as job and instance labels to the remote-written series.
+	Job      string
+	Instance string
+	// Username and Password set basic auth, BearerToken a bearer token.
+	Username    string
+	Password    string
+	BearerToken string
+	// Timeout is the timeout of a push, 30 seconds by default.
+	Timeout time.Duration
+}
+
+// Pusher pushes the metrics of a registry to a Prometheus Pushgateway or a remote-write endpoint.
+type Pusher struct {
+	url      string
+	options  PushOptions
+	registry *Registry
+	client   *http.Client
+}
+
+// NewPusher creates a new instance of Pusher for the url, returning an error if the options are invalid.
+func NewPusher(url string, options PushOptions, registry *Registry) (*Pusher, error) {
+	if url == "" {
+		return nil, fmt.Errorf("missing push url")
+	}
+	switch options.Mode {
+	case "":
+		options.Mode = PUSH_MODE_PUSHGATEWAY
+	case PUSH_MODE_PUSHGATEWAY, PUSH_MODE_REMOTE_WRITE:
+	default:
+		return nil, fmt.Errorf("unsupported push mode: %s", options.Mode)
+	}
+	if options.Job == "" {
+		return nil, fmt.Errorf("missing push job")
+	}
+	if options.BearerToken != "" && options.Username != "" {
+		return nil, fmt.Errorf("push basic auth and bearer token are mutually exclusive")
+	}
+	if options.Timeout == 0 {
+		options.Timeout = 30 * time.Second
+	}
+
+	return &Pusher{
+		url:      url,
+		options:  options,
+		registry: registry,
+		client:   &http.Client{Timeout: options.Timeout},
+	}, nil
+}
+
+// Push sends the current contents of the registry. The Pushgateway group is replaced as a whole, so
+// that series which are no longer exported don't linger.
+func (p *Pusher) Push(ctx context.Context) error {
+	if p.options.Mode == PUSH_MODE_REMOTE_WRITE {
+		return p.remoteWrite(ctx)
+	}
+
+	pusher := push.New(p.url, p.options.Job).Gatherer(p.registry.Gatherer()).Client(p.client)
+	if p.options.Instance != "" {
+		pusher = pusher.Grouping("instance", p.options.Instance)
+	}
+	if p.options.Username != "" {
+		pusher = pusher.BasicAuth(p.options.Username, p.options.Password)
+	}
+	if p.options.BearerToken != "" {
+		pusher = pusher.Header(http.Header{"Authorization": []string{"Bearer " + p.options.BearerToken}})
+	}
+
+	return pusher.PushContext(ctx)
+}
+
+// setAuth sets the credentials of the push on the request.
+func (p *Pusher) setAuth(req *http.Request) {
+	if p.options.Username != "" {
+		req.SetBasicAuth(p.options.Username, p.options.Password)
+	}
+	if p.options.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+p.options.BearerToken)
+	}
+}
@@ -0,0 +1,289 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// writeRequestDescriptor returns the descriptor of prometheus.WriteRequest, as defined by the
+// remote.proto and types.proto files of the remote-write 1.0 specification.
+func writeRequestDescriptor(t *testing.T) protoreflect.MessageDescriptor {
+	field := func(name string, number int32, kind descriptorpb.FieldDescriptorProto_Type, typeName string,
+		repeated bool) *descriptorpb.FieldDescriptorProto {
+		label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+		if repeated {
+			label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+		}
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Type:   kind.Enum(),
+			Label:  label.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	message := descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("remote.proto"),
+		Package: proto.String("prometheus"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("WriteRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("timeseries", 1, message, ".prometheus.TimeSeries", true),
+			}},
+			{Name: proto.String("TimeSeries"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("labels", 1, message, ".prometheus.Label", true),
+				field("samples", 2, message, ".prometheus.Sample", true),
+			}},
+			{Name: proto.String("Label"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false),
+				field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false),
+			}},
+			{Name: proto.String("Sample"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("value", 1, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, "", false),
+				field("timestamp", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, "", false),
+			}},
+		},
+	}
+	fd, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fd.Messages().ByName("WriteRequest")
+}
+
+// writtenSeries is a decoded remote-write series.
+type writtenSeries struct {
+	labels    []string
+	value     float64
+	timestamp int64
+}
+
+// decodeWriteRequest snappy decodes and unmarshals a remote-write body, keyed by the series labels.
+func decodeWriteRequest(t *testing.T, body []byte) map[string]writtenSeries {
+	data, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatalf("invalid snappy body: %v", err)
+	}
+	request := dynamicpb.NewMessage(writeRequestDescriptor(t))
+	if err := proto.Unmarshal(data, request); err != nil {
+		t.Fatalf("invalid WriteRequest: %v", err)
+	}
+
+	series := map[string]writtenSeries{}
+	timeseries := request.Get(request.Descriptor().Fields().ByName("timeseries")).List()
+	for i := 0; i < timeseries.Len(); i++ {
+		ts := timeseries.Get(i).Message()
+		s := writtenSeries{}
+		labels := ts.Get(ts.Descriptor().Fields().ByName("labels")).List()
+		for j := 0; j < labels.Len(); j++ {
+			l := labels.Get(j).Message()
+			s.labels = append(s.labels, l.Get(l.Descriptor().Fields().ByName("name")).String()+"="+
+				l.Get(l.Descriptor().Fields().ByName("value")).String())
+		}
+		samples := ts.Get(ts.Descriptor().Fields().ByName("samples")).List()
+		if samples.Len() != 1 {
+			t.Fatalf("series %v has %d samples, want 1", s.labels, samples.Len())
+		}
+		sample := samples.Get(0).Message()
+		s.value = sample.Get(sample.Descriptor().Fields().ByName("value")).Float()
+		s.timestamp = sample.Get(sample.Descriptor().Fields().ByName("timestamp")).Int()
+		series[strings.Join(s.labels, ",")] = s
+	}
+
+	return series
+}
+
+// newTestRegistry returns a registry holding a gauge, a counter and a histogram.
+func newTestRegistry(t *testing.T) *Registry {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_up"}, []string{"zone", "check"})
+	gauge.WithLabelValues("eu", "quay").Set(1)
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_runs_total"})
+	counter.Add(3)
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "test_duration_seconds",
+		Buckets: []float64{0.5, 1},
+	})
+	histogram.Observe(0.2)
+	histogram.Observe(2)
+
+	registry := NewRegistry("test", false)
+	if err := registry.Register(gauge, counter, histogram); err != nil {
+		t.Fatal(err)
+	}
+
+	return registry
+}
+
+func TestRemoteWrite(t *testing.T) {
+	var body []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ = io.ReadAll(req.Body)
+		header = req.Header
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	pusher, err := NewPusher(server.URL, PushOptions{Mode: PUSH_MODE_REMOTE_WRITE, Job: "availability",
+		Instance: "monitor-0"}, newTestRegistry(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now().UnixMilli()
+	if err := pusher.Push(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	after := time.Now().UnixMilli()
+
+	for name, want := range map[string]string{
+		"Content-Encoding":                  "snappy",
+		"Content-Type":                      "application/x-protobuf",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	} {
+		if got := header.Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+
+	series := decodeWriteRequest(t, body)
+	want := map[string]float64{
+		"__name__=test_up,check=quay,instance=monitor-0,job=availability,zone=eu":           1,
+		"__name__=test_runs_total,instance=monitor-0,job=availability":                      3,
+		"__name__=test_duration_seconds_bucket,instance=monitor-0,job=availability,le=0.5":  1,
+		"__name__=test_duration_seconds_bucket,instance=monitor-0,job=availability,le=1":    1,
+		"__name__=test_duration_seconds_bucket,instance=monitor-0,job=availability,le=+Inf": 2,
+		"__name__=test_duration_seconds_sum,instance=monitor-0,job=availability":            2.2,
+		"__name__=test_duration_seconds_count,instance=monitor-0,job=availability":          2,
+	}
+	for key, value := range want {
+		s, ok := series[key]
+		if !ok {
+			t.Errorf("series %s not written", key)
+			continue
+		}
+		if s.value != value {
+			t.Errorf("series %s = %v, want %v", key, s.value, value)
+		}
+		if s.timestamp < before || s.timestamp > after {
+			t.Errorf("series %s timestamp %d not within the push", key, s.timestamp)
+		}
+	}
+	for key, s := range series {
+		if !sort.SliceIsSorted(s.labels, func(i, j int) bool { return s.labels[i] < s.labels[j] }) {
+			t.Errorf("labels of %s are not sorted", key)
+		}
+	}
+}
+
+// authServer records the credentials and the path of the requests it receives.
+type authServer struct {
+	username, password, authorization, method, path string
+}
+
+func (s *authServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.username, s.password, _ = req.BasicAuth()
+	s.authorization = req.Header.Get("Authorization")
+	s.method = req.Method
+	s.path = req.URL.Path
+	w.WriteHeader(http.StatusOK)
+}
+
+func TestPushAuth(t *testing.T) {
+	tests := []struct {
+		name              string
+		options           PushOptions
+		wantUser          string
+		wantAuthorization string
+	}{
+		{"basic", PushOptions{Username: "user", Password: "secret"}, "user", "Basic dXNlcjpzZWNyZXQ="},
+		{"bearer", PushOptions{BearerToken: "token"}, "", "Bearer token"},
+	}
+	modes := map[string]string{
+		PUSH_MODE_PUSHGATEWAY:  "/metrics/job/availability/instance/monitor-0",
+		PUSH_MODE_REMOTE_WRITE: "/api/v1/write",
+	}
+	for mode, path := range modes {
+		for _, tt := range tests {
+			t.Run(mode+" "+tt.name, func(t *testing.T) {
+				recorded := &authServer{}
+				server := httptest.NewServer(recorded)
+				defer server.Close()
+
+				options := tt.options
+				options.Mode = mode
+				options.Job = "availability"
+				options.Instance = "monitor-0"
+				url := server.URL
+				if mode == PUSH_MODE_REMOTE_WRITE {
+					url += path
+				}
+				pusher, err := NewPusher(url, options, newTestRegistry(t))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := pusher.Push(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+
+				if recorded.authorization != tt.wantAuthorization || recorded.username != tt.wantUser {
+					t.Errorf("Authorization = %q, want %q", recorded.authorization, tt.wantAuthorization)
+				}
+				wantMethod := http.MethodPut
+				if mode == PUSH_MODE_REMOTE_WRITE {
+					wantMethod = http.MethodPost
+				}
+				if got := []string{recorded.method, recorded.path}; !reflect.DeepEqual(got, []string{wantMethod, path}) {
+					t.Errorf("request = %v, want %s %s", got, wantMethod, path)
+				}
+			})
+		}
+	}
+}
+
+func TestNewPusherInvalidOptions(t *testing.T) {
+	tests := map[string]PushOptions{
+		"missing job":          {},
+		"unknown mode":         {Mode: "graphite", Job: "availability"},
+		"basic auth and token": {Job: "availability", Username: "user", BearerToken: "token"},
+	}
+	for name, options := range tests {
+		if _, err := NewPusher("http://localhost:9091", options, nil); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds the collectors exported by the service, apart from the global prometheus registry
+type Registry struct {
+	registry *prometheus.Registry
+}
+
+// NewRegistry creates a new instance of Registry exporting the <prefix>_build_info metric. The go
+// runtime and process collectors are only registered when runtimeCollectors is set.
+func NewRegistry(prefix string, runtimeCollectors bool) *Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(newBuildInfo(prefix))
+	if runtimeCollectors {
+		registry.MustRegister(
+			collectors.NewGoCollector(),
+			collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		)
+	}
+
+	return &Registry{registry: registry}
+}
+
+// Register registers the collectors, failing on the first one which can't be registered
+func (r *Registry) Register(cs ...prometheus.Collector) error {
+	for _, c := range cs {
+		if err := r.registry.Register(c); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Gatherer returns the registry as a gatherer, for exporters pushing its metrics
+func (r *Registry) Gatherer() prometheus.Gatherer {
+	return r.registry
+}
+
+// Handler returns the HTTP handler exposing the metrics of the registry
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{Registry: r.registry})
+}
+
+// newBuildInfo returns the <prefix>_build_info gauge, set to 1 and labeled with the version, the vcs
+// revision and the go version the service was built with.
+func newBuildInfo(prefix string) prometheus.Collector {
+	version, revision, goVersion := "unknown", "unknown", "unknown"
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version = info.Main.Version
+		goVersion = info.GoVersion
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				revision = setting.Value
+			}
+		}
+	}
+
+	return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: fmt.Sprintf("%s_build_info", strings.ToLower(prefix)),
+		Help: fmt.Sprintf("%s build_info", prefix),
+		ConstLabels: prometheus.Labels{
+			"version":   version,
+			"revision":  revision,
+			"goversion": goVersion,
+		},
+	}, func() float64 { return 1 })
+}
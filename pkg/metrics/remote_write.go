@@ -0,0 +1,170 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// label is a label of a remote-written series.
+type label struct {
+	name, value string
+}
+
+// remoteWrite sends the registry contents as a remote-write 1.0 request: a snappy compressed
+// prometheus.WriteRequest protobuf.
+func (p *Pusher) remoteWrite(ctx context.Context) error {
+	families, err := p.registry.Gatherer().Gather()
+	if err != nil {
+		return err
+	}
+
+	body := snappy.Encode(nil, p.encodeWriteRequest(families, time.Now().UnixMilli()))
+	req, err := http.NewRequestWithContext(ctx, "POST", p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	p.setAuth(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("remote write failed with status: %s %s", resp.Status, bytes.TrimSpace(message))
+	}
+
+	return nil
+}
+
+// encodeWriteRequest encodes the metric families as the time series of a WriteRequest. Summaries and
+// histograms are split in their quantile or bucket, sum and count series, the way Prometheus scrapes
+// them. Samples without a timestamp get now.
+func (p *Pusher) encodeWriteRequest(families []*dto.MetricFamily, now int64) []byte {
+	var request []byte
+	for _, family := range families {
+		name := family.GetName()
+		for _, m := range family.GetMetric() {
+			timestamp := now
+			if m.TimestampMs != nil {
+				timestamp = m.GetTimestampMs()
+			}
+			series := func(suffix string, value float64, extra ...label) {
+				labels := p.seriesLabels(name+suffix, m.GetLabel(), extra)
+				request = protowire.AppendTag(request, 1, protowire.BytesType)
+				request = protowire.AppendBytes(request, encodeSeries(labels, value, timestamp))
+			}
+
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				series("", m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				series("", m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				series("", m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				summary := m.GetSummary()
+				for _, q := range summary.GetQuantile() {
+					series("", q.GetValue(), label{"quantile", formatFloat(q.GetQuantile())})
+				}
+				series("_sum", summary.GetSampleSum())
+				series("_count", float64(summary.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				histogram := m.GetHistogram()
+				infinity := false
+				for _, bucket := range histogram.GetBucket() {
+					infinity = infinity || math.IsInf(bucket.GetUpperBound(), 1)
+					series("_bucket", float64(bucket.GetCumulativeCount()),
+						label{"le", formatFloat(bucket.GetUpperBound())})
+				}
+				if !infinity {
+					series("_bucket", float64(histogram.GetSampleCount()), label{"le", "+Inf"})
+				}
+				series("_sum", histogram.GetSampleSum())
+				series("_count", float64(histogram.GetSampleCount()))
+			}
+		}
+	}
+
+	return request
+}
+
+// seriesLabels returns the sorted labels of a series, adding the job and instance of the push unless
+// the metric sets them.
+func (p *Pusher) seriesLabels(name string, pairs []*dto.LabelPair, extra []label) []label {
+	labels := []label{{"__name__", name}}
+	set := map[string]bool{}
+	for _, pair := range pairs {
+		labels = append(labels, label{pair.GetName(), pair.GetValue()})
+		set[pair.GetName()] = true
+	}
+	labels = append(labels, extra...)
+	if !set["job"] {
+		labels = append(labels, label{"job", p.options.Job})
+	}
+	if !set["instance"] && p.options.Instance != "" {
+		labels = append(labels, label{"instance", p.options.Instance})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+
+	return labels
+}
+
+// encodeSeries encodes a TimeSeries holding a single sample.
+func encodeSeries(labels []label, value float64, timestamp int64) []byte {
+	var series []byte
+	for _, l := range labels {
+		var encoded []byte
+		encoded = protowire.AppendTag(encoded, 1, protowire.BytesType)
+		encoded = protowire.AppendString(encoded, l.name)
+		encoded = protowire.AppendTag(encoded, 2, protowire.BytesType)
+		encoded = protowire.AppendString(encoded, l.value)
+		series = protowire.AppendTag(series, 1, protowire.BytesType)
+		series = protowire.AppendBytes(series, encoded)
+	}
+
+	var sample []byte
+	sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+	sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(timestamp))
+	series = protowire.AppendTag(series, 2, protowire.BytesType)
+
+	return protowire.AppendBytes(series, sample)
+}
+
+// formatFloat formats a quantile or bucket bound the way Prometheus does.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,338 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BurnRateWindows are the windows the error budget burn rate is exported for, as used by multiwindow
+// burn rate alerts
+var BurnRateWindows = map[string]time.Duration{
+	"5m":  5 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"6h":  6 * time.Hour,
+	"1d":  24 * time.Hour,
+	"3d":  3 * 24 * time.Hour,
+}
+
+// SLO_GROUP_TYPE is the type label of the series of a group objective
+const SLO_GROUP_TYPE = "group"
+
+// SLOMetric computes the availability of the checks with an objective from their run history
+type SLOMetric struct {
+	// Availability is the ratio of successful runs over the objective window
+	Availability GaugeMetric
+	// ErrorBudgetRemaining is the ratio of the error budget left over the objective window
+	ErrorBudgetRemaining GaugeMetric
+	// BurnRate is the rate the error budget is spent at over each of the BurnRateWindows
+	BurnRate GaugeMetric
+	// Objective is the availability target ratio
+	Objective GaugeMetric
+
+	labels     []string
+	stateDir   string
+	log        *log.Logger
+	mu         sync.Mutex
+	objectives map[string]*objective
+}
+
+// objective holds the target and the run history of a check, or of a group of checks
+type objective struct {
+	target float64
+	window time.Duration
+	// checks and selector select the checks of a group objective, like a maintenance window
+	checks   []string
+	selector map[string]string
+	// metadata are the label values of the series of a group objective. The series of a check objective
+	// carry the label values of the check.
+	metadata []string
+	// buckets counts the runs by minute
+	buckets map[int64]*runBucket
+	// dirty is set when runs were recorded since the history was saved
+	dirty bool
+}
+
+// runBucket counts the runs of a minute
+type runBucket struct {
+	Good  int `json:"good"`
+	Total int `json:"total"`
+}
+
+// NewSLOMetric creates a new instance of SLOMetric, labeled like the CompositeMetric. The run history is
+// persisted in stateDir by Save, or only kept in memory if stateDir is empty.
+func NewSLOMetric(prefix string, staticLabels []string, stateDir string, log *log.Logger) *SLOMetric {
+	labels := append(append([]string{}, CheckLabels...), staticLabels...)
+
+	return &SLOMetric{
+		Availability:         NewNamedGaugeMetric(prefix, "slo_availability_ratio", labels),
+		ErrorBudgetRemaining: NewNamedGaugeMetric(prefix, "slo_error_budget_remaining", labels),
+		BurnRate:             NewNamedGaugeMetric(prefix, "slo_burn_rate", withLabel(labels, "window")),
+		Objective:            NewNamedGaugeMetric(prefix, "slo_objective_ratio", labels),
+		labels:               labels,
+		stateDir:             stateDir,
+		log:                  log,
+		objectives:           map[string]*objective{},
+	}
+}
+
+// Collectors returns the prometheus collectors of a SLOMetric
+func (sm *SLOMetric) Collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		sm.Availability.Metric,
+		sm.ErrorBudgetRemaining.Metric,
+		sm.BurnRate.Metric,
+		sm.Objective.Metric,
+	}
+}
+
+// AddObjective sets the availability target ratio of a check over the window. The run history persisted
+// by a previous instance is loaded.
+func (sm *SLOMetric) AddObjective(check string, target float64, window time.Duration) error {
+	return sm.addObjective(check, &objective{target: target, window: window, checks: []string{check}})
+}
+
+// AddGroupObjective sets the availability target ratio over the window of the runs of the checks listed
+// in checks and matching the label selector. Its series are labeled with the name as check, the
+// SLO_GROUP_TYPE type and an empty target.
+func (sm *SLOMetric) AddGroupObjective(name string, checks []string, selector map[string]string, target float64,
+	window time.Duration) error {
+	if len(checks) == 0 && len(selector) == 0 {
+		return fmt.Errorf("slo %s has no checks or selector", name)
+	}
+	for label := range selector {
+		if !slices.Contains(sm.labels, label) {
+			return fmt.Errorf("slo %s selects on the unknown label %s", name, label)
+		}
+	}
+
+	metadata := make([]string, len(sm.labels))
+	metadata[0], metadata[1] = name, SLO_GROUP_TYPE
+
+	return sm.addObjective(name, &objective{target: target, window: window, checks: checks, selector: selector,
+		metadata: metadata})
+}
+
+// addObjective validates the objective, loads its persisted run history and adds it under the name.
+func (sm *SLOMetric) addObjective(name string, obj *objective) error {
+	if obj.target <= 0 || obj.target >= 1 {
+		return fmt.Errorf("slo target of %s must be between 0 and 100%%", name)
+	}
+	if obj.window < time.Minute {
+		return fmt.Errorf("slo window of %s must be at least a minute", name)
+	}
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if _, ok := sm.objectives[name]; ok {
+		return fmt.Errorf("slo %s is defined twice", name)
+	}
+	obj.buckets = map[int64]*runBucket{}
+	if err := sm.load(name, obj); err != nil {
+		return err
+	}
+	sm.objectives[name] = obj
+
+	return nil
+}
+
+// Record adds a run to the history of the objectives selecting the check and exports their SLO. The
+// metadata holds the check label values.
+func (sm *SLOMetric) Record(metadata []string, success bool) {
+	sm.record(metadata, success, time.Now())
+}
+
+// record adds a run at the given time.
+func (sm *SLOMetric) record(metadata []string, success bool, now time.Time) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	labels := map[string]string{}
+	for i, name := range sm.labels {
+		labels[name] = metadata[i]
+	}
+	for _, obj := range sm.objectives {
+		if !obj.selects(labels) {
+			continue
+		}
+		obj.add(now, success)
+
+		series := obj.metadata
+		if series == nil {
+			series = metadata
+		}
+		sm.Objective.Record(series, obj.target)
+		if availability, ok := obj.availability(now, obj.window); ok {
+			sm.Availability.Record(series, availability)
+			sm.ErrorBudgetRemaining.Record(series, 1-(1-availability)/(1-obj.target))
+		}
+		for name, window := range BurnRateWindows {
+			if availability, ok := obj.availability(now, window); ok {
+				sm.BurnRate.Record(withLabel(series, name), (1-availability)/(1-obj.target))
+			}
+		}
+	}
+}
+
+// Save persists the run history of the objectives which recorded runs since the last save. It is called
+// periodically and on shutdown, rather than on every run, as the history of a 30 days window holds up
+// to 43200 buckets.
+func (sm *SLOMetric) Save() {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	for name, obj := range sm.objectives {
+		if !obj.dirty {
+			continue
+		}
+		if err := sm.save(name, obj); err != nil {
+			sm.log.Printf("[ERROR] failed to save the slo history of %s: %v\n", name, err)
+			continue
+		}
+		obj.dirty = false
+	}
+}
+
+// selects returns true when the check with the given labels is listed in the checks of the objective,
+// if any, and matches its selector.
+func (o *objective) selects(labels map[string]string) bool {
+	if len(o.checks) != 0 && !slices.Contains(o.checks, labels["check"]) {
+		return false
+	}
+	for name, value := range o.selector {
+		if labels[name] != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// add counts a run in the bucket of its minute and drops the expired buckets.
+func (o *objective) add(now time.Time, success bool) {
+	minute := now.Unix() / 60
+	bucket, ok := o.buckets[minute]
+	if !ok {
+		bucket = &runBucket{}
+		o.buckets[minute] = bucket
+	}
+	bucket.Total++
+	if success {
+		bucket.Good++
+	}
+	o.dirty = true
+	o.prune(now)
+}
+
+// availability returns the ratio of successful runs over the window ending now. False is returned when
+// no run happened in the window.
+func (o *objective) availability(now time.Time, window time.Duration) (float64, bool) {
+	from := now.Add(-window).Unix() / 60
+	good, total := 0, 0
+	for minute, bucket := range o.buckets {
+		if minute > from {
+			good += bucket.Good
+			total += bucket.Total
+		}
+	}
+	if total == 0 {
+		return 0, false
+	}
+
+	return float64(good) / float64(total), true
+}
+
+// prune drops the buckets older than both the objective window and the burn rate windows.
+func (o *objective) prune(now time.Time) {
+	retention := o.window
+	for _, window := range BurnRateWindows {
+		retention = max(retention, window)
+	}
+	from := now.Add(-retention).Unix() / 60
+	for minute := range o.buckets {
+		if minute <= from {
+			delete(o.buckets, minute)
+		}
+	}
+}
+
+// historyFile returns the file the run history of an objective is persisted to.
+func (sm *SLOMetric) historyFile(name string) string {
+	return filepath.Join(sm.stateDir, fmt.Sprintf("slo-%s.json", url.PathEscape(name)))
+}
+
+// load reads the persisted run history of an objective.
+func (sm *SLOMetric) load(name string, obj *objective) error {
+	if sm.stateDir == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(sm.historyFile(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, &obj.buckets); err != nil {
+		return fmt.Errorf("invalid slo history of %s: %v", name, err)
+	}
+	obj.prune(time.Now())
+
+	return nil
+}
+
+// save persists the run history of an objective. The file is replaced atomically.
+func (sm *SLOMetric) save(name string, obj *objective) error {
+	if sm.stateDir == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(obj.buckets)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(sm.stateDir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(sm.stateDir, ".slo-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), sm.historyFile(name))
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package metrics
+
+import (
+	"io"
+	"log"
+	"math"
+	"os"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// sloGauge returns the value of a series of an SLO gauge, failing if it is not set.
+func sloGauge(t *testing.T, gauge GaugeMetric, labels ...string) float64 {
+	t.Helper()
+	series, err := gauge.Metric.GetMetricWithLabelValues(labels...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	metric := &dto.Metric{}
+	if err := series.Write(metric); err != nil {
+		t.Fatal(err)
+	}
+
+	return metric.GetGauge().GetValue()
+}
+
+// assertRatio fails if got differs from want beyond rounding errors.
+func assertRatio(t *testing.T, name string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-9 {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
+
+func newTestSLOMetric(stateDir string) *SLOMetric {
+	return NewSLOMetric("test", []string{"team"}, stateDir, log.New(io.Discard, "", 0))
+}
+
+func TestSLOBurnRateAndErrorBudget(t *testing.T) {
+	slo := newTestSLOMetric("")
+	if err := slo.AddObjective("github", 0.9, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	metadata := []string{"github", "git", "https://github.com/org/repo", "release"}
+
+	// 9 successful runs 40 minutes ago, then a failure a minute ago
+	now := time.Now()
+	for i := 0; i < 9; i++ {
+		slo.record(metadata, true, now.Add(-40*time.Minute+time.Duration(i)*time.Minute))
+	}
+	slo.record(metadata, false, now.Add(-time.Minute))
+
+	assertRatio(t, "objective", sloGauge(t, slo.Objective, metadata...), 0.9)
+	assertRatio(t, "availability", sloGauge(t, slo.Availability, metadata...), 0.9)
+	// the failure spent the whole budget of the window
+	assertRatio(t, "error budget", sloGauge(t, slo.ErrorBudgetRemaining, metadata...), 0)
+	wantBurnRates := map[string]float64{"5m": 10, "30m": 10, "1h": 1, "6h": 1, "1d": 1, "3d": 1}
+	for window, want := range wantBurnRates {
+		assertRatio(t, "burn rate "+window, sloGauge(t, slo.BurnRate, withLabel(metadata, window)...), want)
+	}
+
+	// a second failure overspends it
+	slo.record(metadata, false, now)
+	assertRatio(t, "availability", sloGauge(t, slo.Availability, metadata...), 9.0/11)
+	assertRatio(t, "error budget", sloGauge(t, slo.ErrorBudgetRemaining, metadata...), 1-(2.0/11)/0.1)
+}
+
+func TestSLOPrunesExpiredRuns(t *testing.T) {
+	slo := newTestSLOMetric("")
+	if err := slo.AddObjective("github", 0.9, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	metadata := []string{"github", "git", "", ""}
+
+	// the failure is older than the window and the 3d burn rate window
+	now := time.Now()
+	slo.record(metadata, false, now.Add(-4*24*time.Hour))
+	slo.record(metadata, true, now)
+
+	assertRatio(t, "availability", sloGauge(t, slo.Availability, metadata...), 1)
+	if n := len(slo.objectives["github"].buckets); n != 1 {
+		t.Errorf("%d buckets kept, want 1", n)
+	}
+}
+
+func TestSLOGroupObjective(t *testing.T) {
+	slo := newTestSLOMetric("")
+	if err := slo.AddGroupObjective("release", nil, map[string]string{"team": "release"}, 0.5,
+		time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := slo.AddObjective("quay", 0.9, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	slo.record([]string{"github", "git", "", "release"}, true, now)
+	slo.record([]string{"quay", "quay", "", "release"}, false, now)
+	slo.record([]string{"other", "http", "", "other"}, false, now)
+
+	group := []string{"release", SLO_GROUP_TYPE, "", ""}
+	assertRatio(t, "group availability", sloGauge(t, slo.Availability, group...), 0.5)
+	assertRatio(t, "group error budget", sloGauge(t, slo.ErrorBudgetRemaining, group...), 0)
+	// the check objective of a grouped check is computed on its own
+	assertRatio(t, "check availability", sloGauge(t, slo.Availability, "quay", "quay", "", "release"), 0)
+}
+
+func TestSLOInvalidObjectives(t *testing.T) {
+	slo := newTestSLOMetric("")
+	if err := slo.AddObjective("github", 0.9, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]func() error{
+		"target of 100%": func() error { return slo.AddObjective("quay", 1, time.Hour) },
+		"short window":   func() error { return slo.AddObjective("quay", 0.9, time.Second) },
+		"duplicate":      func() error { return slo.AddGroupObjective("github", []string{"quay"}, nil, 0.9, time.Hour) },
+		"empty group":    func() error { return slo.AddGroupObjective("group", nil, nil, 0.9, time.Hour) },
+		"unknown label": func() error {
+			return slo.AddGroupObjective("group", nil, map[string]string{"env": "prod"}, 0.9, time.Hour)
+		},
+	}
+	for name, add := range tests {
+		t.Run(name, func(t *testing.T) {
+			if err := add(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestSLOSave(t *testing.T) {
+	stateDir := t.TempDir()
+	slo := newTestSLOMetric(stateDir)
+	if err := slo.AddObjective("github", 0.9, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	metadata := []string{"github", "git", "", ""}
+	slo.Record(metadata, true)
+	slo.Record(metadata, false)
+
+	// the history is only written by Save
+	if _, err := os.Stat(slo.historyFile("github")); !os.IsNotExist(err) {
+		t.Fatalf("history written on record: %v", err)
+	}
+	slo.Save()
+	if slo.objectives["github"].dirty {
+		t.Error("history still dirty after a save")
+	}
+
+	restarted := newTestSLOMetric(stateDir)
+	if err := restarted.AddObjective("github", 0.9, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	restarted.Record(metadata, true)
+	assertRatio(t, "availability", sloGauge(t, restarted.Availability, metadata...), 2.0/3)
+}
@@ -0,0 +1,214 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package report
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/hacbs-release/release-availability-metrics/pkg/checks"
+)
+
+const (
+	FORMAT_TABLE = "table"
+	FORMAT_JSON  = "json"
+	FORMAT_JUNIT = "junit"
+
+	STATUS_SUCCEEDED = "succeeded"
+	STATUS_FAILED    = "failed"
+	STATUS_SKIPPED   = "skipped"
+)
+
+// ValidFormat returns an error if the report format is not supported.
+func ValidFormat(format string) error {
+	switch format {
+	case FORMAT_TABLE, FORMAT_JSON, FORMAT_JUNIT:
+		return nil
+	}
+
+	return fmt.Errorf("unsupported output format: %s", format)
+}
+
+// Failed returns whether one of the checks did not succeed, skipped checks included.
+func Failed(results []checks.Result) bool {
+	for _, result := range results {
+		if result.Code != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Write writes the results of a run in the given format: an aligned table, a JSON document or a JUnit
+// XML report with a test case per check.
+func Write(w io.Writer, format string, results []checks.Result) error {
+	switch format {
+	case FORMAT_TABLE:
+		return writeTable(w, results)
+	case FORMAT_JSON:
+		return writeJSON(w, results)
+	case FORMAT_JUNIT:
+		return writeJUnit(w, results)
+	}
+
+	return ValidFormat(format)
+}
+
+// status returns the status of a result.
+func status(result checks.Result) string {
+	switch {
+	case result.Code == checks.SKIPPED_CODE:
+		return STATUS_SKIPPED
+	case result.Code != 0:
+		return STATUS_FAILED
+	}
+
+	return STATUS_SUCCEEDED
+}
+
+// reason returns the failure message of a result, or the failing dependency of a skipped check.
+func reason(result checks.Result) string {
+	switch {
+	case result.SkippedBy != "":
+		return fmt.Sprintf("dependency %s did not succeed", result.SkippedBy)
+	case result.Err != nil:
+		return result.Err.Error()
+	case result.Code != 0:
+		return "failed"
+	}
+
+	return ""
+}
+
+// writeTable writes the results as an aligned table, followed by a summary line.
+func writeTable(w io.Writer, results []checks.Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDURATION\tREASON")
+	counts := map[string]int{}
+	for _, result := range results {
+		counts[status(result)]++
+		duration := "-"
+		if result.SkippedBy == "" {
+			duration = result.Duration.Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", result.Name, status(result), duration, reason(result))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d checks: %d succeeded, %d failed, %d skipped\n", len(results),
+		counts[STATUS_SUCCEEDED], counts[STATUS_FAILED], counts[STATUS_SKIPPED])
+
+	return err
+}
+
+// jsonResult is the JSON representation of a result.
+type jsonResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Duration  float64 `json:"duration_seconds"`
+	Reason    string  `json:"reason,omitempty"`
+	SkippedBy string  `json:"skipped_by,omitempty"`
+}
+
+// writeJSON writes the results as a JSON document.
+func writeJSON(w io.Writer, results []checks.Result) error {
+	doc := struct {
+		Failed bool         `json:"failed"`
+		Checks []jsonResult `json:"checks"`
+	}{Failed: Failed(results), Checks: []jsonResult{}}
+	for _, result := range results {
+		doc.Checks = append(doc.Checks, jsonResult{
+			Name:      result.Name,
+			Status:    status(result),
+			Duration:  result.Duration.Seconds(),
+			Reason:    reason(result),
+			SkippedBy: result.SkippedBy,
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(doc)
+}
+
+// junitTestSuite is a JUnit XML test suite, holding a test case per check.
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+// junitTestCase is a JUnit XML test case.
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+// junitMessage is the failure or skip message of a JUnit XML test case.
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// writeJUnit writes the results as a JUnit XML report.
+func writeJUnit(w io.Writer, results []checks.Result) error {
+	suite := junitTestSuite{Name: "availability", Tests: len(results)}
+	var total time.Duration
+	for _, result := range results {
+		total += result.Duration
+		testCase := junitTestCase{
+			Name:      result.Name,
+			ClassName: suite.Name,
+			Time:      fmt.Sprintf("%.3f", result.Duration.Seconds()),
+		}
+		switch status(result) {
+		case STATUS_FAILED:
+			suite.Failures++
+			testCase.Failure = &junitMessage{Message: reason(result)}
+		case STATUS_SKIPPED:
+			suite.Skipped++
+			testCase.Skipped = &junitMessage{Message: reason(result)}
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	suite.Time = fmt.Sprintf("%.3f", total.Seconds())
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+
+	return err
+}
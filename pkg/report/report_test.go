@@ -0,0 +1,79 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package report
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hacbs-release/release-availability-metrics/pkg/checks"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// results holds a succeeded, a skipped and two failed checks, one of them a composite check.
+var results = []checks.Result{
+	{Name: "release-repo", Code: 0, Duration: 412 * time.Millisecond},
+	{Name: "quay-image", Code: 1, Err: errors.New("manifest check failed with status: 404"),
+		Duration: 95 * time.Millisecond},
+	{Name: "image-signed", Code: checks.SKIPPED_CODE, SkippedBy: "quay-image"},
+	{Name: "release", Code: 1, Err: errors.New("checks failed: quay-image"), Duration: 1500 * time.Microsecond},
+}
+
+func TestWrite(t *testing.T) {
+	for _, format := range []string{FORMAT_TABLE, FORMAT_JSON, FORMAT_JUNIT} {
+		t.Run(format, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := Write(&out, format, results); err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", format+".golden")
+			if *update {
+				if err := os.WriteFile(golden, out.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("%s report differs from %s:\n%s\nwant:\n%s", format, golden, out.Bytes(), want)
+			}
+		})
+	}
+}
+
+func TestWriteUnsupportedFormat(t *testing.T) {
+	if err := Write(&bytes.Buffer{}, "yaml", results); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestFailed(t *testing.T) {
+	if !Failed(results) {
+		t.Error("failed results reported as succeeded")
+	}
+	if Failed(results[:1]) {
+		t.Error("succeeded results reported as failed")
+	}
+}
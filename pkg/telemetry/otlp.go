@@ -0,0 +1,168 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	PROTOCOL_GRPC = "grpc"
+	PROTOCOL_HTTP = "http"
+)
+
+// Options stores the OTLP endpoint settings.
+type Options struct {
+	// Endpoint is the host:port of the OTLP receiver, or its http:// or https:// URL.
+	Endpoint string
+	// Protocol is grpc, the default, or http.
+	Protocol string
+	// Insecure disables TLS.
+	Insecure bool
+	// Headers are sent with every export, like the credentials of the receiver.
+	Headers map[string]string
+	// Interval is the metrics export interval, 60 seconds by default.
+	Interval time.Duration
+	// ServiceName is the service.name resource attribute.
+	ServiceName string
+}
+
+// Exporter pushes the metrics gathered from a prometheus registry and the spans of the check runs to an
+// OTLP receiver.
+type Exporter struct {
+	meterProvider  *sdkmetric.MeterProvider
+	tracerProvider *sdktrace.TracerProvider
+}
+
+// NewExporter creates a new instance of Exporter pushing the metrics of the gatherer every interval. It
+// is set as the global tracer provider, so that the check runs are traced. The exporters connect lazily,
+// an unreachable receiver doesn't fail here but only logs the failed exports.
+func NewExporter(ctx context.Context, options Options, gatherer prometheus.Gatherer) (*Exporter, error) {
+	endpoint, insecure, err := parseEndpoint(options.Endpoint, options.Insecure)
+	if err != nil {
+		return nil, err
+	}
+	interval := options.Interval
+	if interval == 0 {
+		interval = 60 * time.Second
+	}
+
+	var metricExporter sdkmetric.Exporter
+	var traceExporter *otlptrace.Exporter
+	switch options.Protocol {
+	case "", PROTOCOL_GRPC:
+		metricOptions := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(endpoint)}
+		traceOptions := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint)}
+		if insecure {
+			metricOptions = append(metricOptions, otlpmetricgrpc.WithInsecure())
+			traceOptions = append(traceOptions, otlptracegrpc.WithInsecure())
+		}
+		if len(options.Headers) != 0 {
+			metricOptions = append(metricOptions, otlpmetricgrpc.WithHeaders(options.Headers))
+			traceOptions = append(traceOptions, otlptracegrpc.WithHeaders(options.Headers))
+		}
+		if metricExporter, err = otlpmetricgrpc.New(ctx, metricOptions...); err != nil {
+			return nil, err
+		}
+		traceExporter, err = otlptracegrpc.New(ctx, traceOptions...)
+	case PROTOCOL_HTTP:
+		metricOptions := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(endpoint)}
+		traceOptions := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+		if insecure {
+			metricOptions = append(metricOptions, otlpmetrichttp.WithInsecure())
+			traceOptions = append(traceOptions, otlptracehttp.WithInsecure())
+		}
+		if len(options.Headers) != 0 {
+			metricOptions = append(metricOptions, otlpmetrichttp.WithHeaders(options.Headers))
+			traceOptions = append(traceOptions, otlptracehttp.WithHeaders(options.Headers))
+		}
+		if metricExporter, err = otlpmetrichttp.New(ctx, metricOptions...); err != nil {
+			return nil, err
+		}
+		traceExporter, err = otlptracehttp.New(ctx, traceOptions...)
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol: %s", options.Protocol)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence over the service name
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", options.ServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK())
+	if err != nil {
+		return nil, err
+	}
+
+	reader := sdkmetric.NewPeriodicReader(metricExporter,
+		sdkmetric.WithInterval(interval),
+		sdkmetric.WithProducer(newGathererProducer(gatherer)))
+	e := &Exporter{
+		meterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithResource(res)),
+		tracerProvider: sdktrace.NewTracerProvider(sdktrace.WithBatcher(traceExporter), sdktrace.WithResource(res)),
+	}
+	otel.SetTracerProvider(e.tracerProvider)
+
+	return e, nil
+}
+
+// Shutdown flushes and stops the exporters.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.meterProvider.Shutdown(ctx), e.tracerProvider.Shutdown(ctx))
+}
+
+// parseEndpoint returns the host:port of the endpoint. The scheme of an endpoint URL sets whether TLS
+// is used, http:// being insecure.
+func parseEndpoint(endpoint string, insecure bool) (string, bool, error) {
+	if endpoint == "" {
+		return "", false, fmt.Errorf("missing OTLP endpoint")
+	}
+	if !strings.Contains(endpoint, "://") {
+		return endpoint, insecure, nil
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", false, err
+	}
+	switch u.Scheme {
+	case "http":
+		return u.Host, true, nil
+	case "https":
+		return u.Host, insecure, nil
+	}
+
+	return "", false, fmt.Errorf("unsupported OTLP endpoint scheme: %s", u.Scheme)
+}
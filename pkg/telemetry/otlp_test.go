@@ -0,0 +1,349 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/hacbs-release/release-availability-metrics/pkg/checks"
+	"github.com/hacbs-release/release-availability-metrics/pkg/metrics"
+)
+
+// receiver stores the spans and metrics exported to it.
+type receiver struct {
+	mu      sync.Mutex
+	spans   []*tracepb.Span
+	metrics []*metricpb.Metric
+	headers []string
+}
+
+func (r *receiver) addTraces(req *coltracepb.ExportTraceServiceRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, resourceSpans := range req.GetResourceSpans() {
+		for _, scopeSpans := range resourceSpans.GetScopeSpans() {
+			r.spans = append(r.spans, scopeSpans.GetSpans()...)
+		}
+	}
+}
+
+func (r *receiver) addMetrics(req *colmetricpb.ExportMetricsServiceRequest) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, resourceMetrics := range req.GetResourceMetrics() {
+		for _, scopeMetrics := range resourceMetrics.GetScopeMetrics() {
+			r.metrics = append(r.metrics, scopeMetrics.GetMetrics()...)
+		}
+	}
+}
+
+type traceService struct {
+	coltracepb.UnimplementedTraceServiceServer
+	r *receiver
+}
+
+func (s traceService) Export(_ context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	s.r.addTraces(req)
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+type metricsService struct {
+	colmetricpb.UnimplementedMetricsServiceServer
+	r *receiver
+}
+
+func (s metricsService) Export(_ context.Context, req *colmetricpb.ExportMetricsServiceRequest) (*colmetricpb.ExportMetricsServiceResponse, error) {
+	s.r.addMetrics(req)
+	return &colmetricpb.ExportMetricsServiceResponse{}, nil
+}
+
+// newGRPCReceiver serves an OTLP gRPC receiver on a loopback listener.
+func newGRPCReceiver(t *testing.T) (*receiver, string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &receiver{}
+	server := grpc.NewServer()
+	coltracepb.RegisterTraceServiceServer(server, traceService{r: r})
+	colmetricpb.RegisterMetricsServiceServer(server, metricsService{r: r})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	return r, listener.Addr().String()
+}
+
+// newHTTPReceiver serves an OTLP HTTP receiver.
+func newHTTPReceiver(t *testing.T) (*receiver, string) {
+	r := &receiver{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.mu.Lock()
+		r.headers = append(r.headers, req.Header.Get("X-Scope-OrgID"))
+		r.mu.Unlock()
+
+		var message proto.Message
+		switch req.URL.Path {
+		case "/v1/traces":
+			traces := &coltracepb.ExportTraceServiceRequest{}
+			defer r.addTraces(traces)
+			message = traces
+		case "/v1/metrics":
+			metrics := &colmetricpb.ExportMetricsServiceRequest{}
+			defer r.addMetrics(metrics)
+			message = metrics
+		default:
+			http.NotFound(w, req)
+			return
+		}
+		if err := proto.Unmarshal(body, message); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	}))
+	t.Cleanup(server.Close)
+
+	return r, server.URL
+}
+
+// newRegistry serves an image manifest behind a bearer token challenge.
+func newRegistry(t *testing.T) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.URL.Path == "/token":
+			fmt.Fprint(w, `{"token": "secret"}`)
+		case req.Header.Get("Authorization") != "Bearer secret":
+			w.Header().Set("WWW-Authenticate",
+				fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case req.Method == http.MethodHead && req.URL.Path == "/v2/org/image/manifests/v1":
+			w.Header().Set("Docker-Content-Digest", "sha256:"+strings.Repeat("0", 64))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// newRepository creates a repository with a single commit holding README.md.
+func newRepository(t *testing.T) string {
+	dir := t.TempDir()
+	r, err := git.PlainInit(dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Add("README.md"); err != nil {
+		t.Fatal(err)
+	}
+	signature := &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+	if _, err := w.Commit("initial", &git.CommitOptions{Author: signature}); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+// runChecks runs a quay and a git check once with the exporter set, then flushes it.
+func runChecks(t *testing.T, options Options) {
+	// the local git transport runs git-upload-pack
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is required to clone the test repository")
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	metric := metrics.NewCompositeMetric("test", nil)
+	gitMetric := metrics.NewGitMetric("test", nil)
+	registry := metrics.NewRegistry("test", false)
+	if err := registry.Register(append(metric.Collectors(), gitMetric.Collectors()...)...); err != nil {
+		t.Fatal(err)
+	}
+
+	exporter, err := NewExporter(context.Background(), options, registry.Gatherer())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	image := strings.TrimPrefix(newRegistry(t).URL, "http://") + "/org/image:v1"
+	quay := checks.NewQuayCheck(checks.NewQuayAuth(""), "quay", image, nil,
+		checks.QuayOptions{PlainHttp: true}, nil, logger, metric)
+	repo := checks.NewGitCheck("test", "git", "", "file://"+newRepository(t), "master", []string{"README.md"},
+		checks.GitOptions{}, nil, logger, metric, gitMetric)
+	scheduler, err := checks.NewScheduler([]checks.Check{quay, repo}, nil, checks.NewResults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range scheduler.Run(context.Background()) {
+		if result.Code != 0 {
+			t.Fatalf("check %s failed: %v", result.Name, result.Err)
+		}
+	}
+
+	if err := exporter.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// assertExported checks that the span of each check arrived with its sub-step events, and that the
+// check_up metric arrived for both checks.
+func assertExported(t *testing.T, r *receiver) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wantEvents := map[string][]string{
+		"check quay": {"auth token fetch", "manifest HEAD"},
+		"check git":  {"clone", "tree lookup"},
+	}
+	for name, events := range wantEvents {
+		var span *tracepb.Span
+		for _, s := range r.spans {
+			if s.GetName() == name {
+				span = s
+			}
+		}
+		if span == nil {
+			t.Errorf("span %q not exported", name)
+			continue
+		}
+		got := map[string]bool{}
+		for _, event := range span.GetEvents() {
+			got[event.GetName()] = true
+		}
+		for _, event := range events {
+			if !got[event] {
+				t.Errorf("span %q is missing the %q event", name, event)
+			}
+		}
+	}
+
+	up := map[string]float64{}
+	for _, metric := range r.metrics {
+		if metric.GetName() != "test_check_up" {
+			continue
+		}
+		for _, dataPoint := range metric.GetGauge().GetDataPoints() {
+			for _, attribute := range dataPoint.GetAttributes() {
+				if attribute.GetKey() == "check" {
+					up[attribute.GetValue().GetStringValue()] = dataPoint.GetAsDouble()
+				}
+			}
+		}
+	}
+	for _, check := range []string{"quay", "git"} {
+		if value, ok := up[check]; !ok || value != 1 {
+			t.Errorf("test_check_up{check=%q} = %v, %v, want 1", check, value, ok)
+		}
+	}
+}
+
+func TestNewExporterGRPC(t *testing.T) {
+	r, endpoint := newGRPCReceiver(t)
+	runChecks(t, Options{Endpoint: endpoint, Insecure: true, ServiceName: "test"})
+	assertExported(t, r)
+}
+
+func TestNewExporterHTTP(t *testing.T) {
+	r, endpoint := newHTTPReceiver(t)
+	runChecks(t, Options{
+		Endpoint:    endpoint,
+		Protocol:    PROTOCOL_HTTP,
+		Headers:     map[string]string{"X-Scope-OrgID": "tenant"},
+		ServiceName: "test",
+	})
+	assertExported(t, r)
+
+	for _, header := range r.headers {
+		if header != "tenant" {
+			t.Errorf("export sent without the configured header: %q", header)
+		}
+	}
+}
+
+func TestNewExporterInvalidOptions(t *testing.T) {
+	tests := map[string]Options{
+		"missing endpoint":   {},
+		"unknown protocol":   {Endpoint: "localhost:4317", Protocol: "udp"},
+		"unsupported scheme": {Endpoint: "ftp://localhost:4317"},
+	}
+	for name, options := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewExporter(context.Background(), options, nil); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint     string
+		insecure     bool
+		wantEndpoint string
+		wantInsecure bool
+	}{
+		{"collector:4317", false, "collector:4317", false},
+		{"collector:4317", true, "collector:4317", true},
+		{"http://collector:4318", false, "collector:4318", true},
+		{"https://collector:4318", false, "collector:4318", false},
+		{"https://collector:4318", true, "collector:4318", true},
+	}
+	for _, tt := range tests {
+		endpoint, insecure, err := parseEndpoint(tt.endpoint, tt.insecure)
+		if err != nil {
+			t.Fatalf("parseEndpoint(%q): %v", tt.endpoint, err)
+		}
+		if endpoint != tt.wantEndpoint || insecure != tt.wantInsecure {
+			t.Errorf("parseEndpoint(%q, %v) = %q, %v, want %q, %v", tt.endpoint, tt.insecure, endpoint, insecure,
+				tt.wantEndpoint, tt.wantInsecure)
+		}
+	}
+}
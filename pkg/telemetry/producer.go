@@ -0,0 +1,150 @@
+/*
+Copyright 2024 Red Hat Inc
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package telemetry
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// scopeName is the instrumentation scope of the metrics gathered from the registry.
+const scopeName = "github.com/hacbs-release/release-availability-metrics/pkg/metrics"
+
+// gathererProducer converts the metrics of a prometheus gatherer for the OTLP exporter, so that the same
+// series as the /metrics endpoint are pushed. Counters and histograms are cumulative since the start of
+// the service.
+type gathererProducer struct {
+	gatherer prometheus.Gatherer
+	start    time.Time
+}
+
+// newGathererProducer returns a new instance of gathererProducer.
+func newGathererProducer(gatherer prometheus.Gatherer) *gathererProducer {
+	return &gathererProducer{gatherer: gatherer, start: time.Now()}
+}
+
+// Produce gathers the metric families and converts them. Families the gatherer failed to collect are
+// left out, along with the native histograms.
+func (p *gathererProducer) Produce(context.Context) ([]metricdata.ScopeMetrics, error) {
+	families, err := p.gatherer.Gather()
+	if len(families) == 0 {
+		return nil, err
+	}
+
+	now := time.Now()
+	metrics := []metricdata.Metrics{}
+	for _, family := range families {
+		m := metricdata.Metrics{Name: family.GetName(), Description: family.GetHelp()}
+		switch family.GetType() {
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			gauge := metricdata.Gauge[float64]{}
+			for _, metric := range family.GetMetric() {
+				value := metric.GetGauge().GetValue()
+				if family.GetType() == dto.MetricType_UNTYPED {
+					value = metric.GetUntyped().GetValue()
+				}
+				gauge.DataPoints = append(gauge.DataPoints, metricdata.DataPoint[float64]{
+					Attributes: attributes(metric),
+					Time:       now,
+					Value:      value,
+				})
+			}
+			m.Data = gauge
+		case dto.MetricType_COUNTER:
+			sum := metricdata.Sum[float64]{Temporality: metricdata.CumulativeTemporality, IsMonotonic: true}
+			for _, metric := range family.GetMetric() {
+				sum.DataPoints = append(sum.DataPoints, metricdata.DataPoint[float64]{
+					Attributes: attributes(metric),
+					StartTime:  p.start,
+					Time:       now,
+					Value:      metric.GetCounter().GetValue(),
+				})
+			}
+			m.Data = sum
+		case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+			histogram := metricdata.Histogram[float64]{Temporality: metricdata.CumulativeTemporality}
+			for _, metric := range family.GetMetric() {
+				histogram.DataPoints = append(histogram.DataPoints, p.histogramDataPoint(metric, now))
+			}
+			m.Data = histogram
+		case dto.MetricType_SUMMARY:
+			summary := metricdata.Summary{}
+			for _, metric := range family.GetMetric() {
+				dataPoint := metricdata.SummaryDataPoint{
+					Attributes: attributes(metric),
+					StartTime:  p.start,
+					Time:       now,
+					Count:      metric.GetSummary().GetSampleCount(),
+					Sum:        metric.GetSummary().GetSampleSum(),
+				}
+				for _, q := range metric.GetSummary().GetQuantile() {
+					dataPoint.QuantileValues = append(dataPoint.QuantileValues,
+						metricdata.QuantileValue{Quantile: q.GetQuantile(), Value: q.GetValue()})
+				}
+				summary.DataPoints = append(summary.DataPoints, dataPoint)
+			}
+			m.Data = summary
+		default:
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+
+	return []metricdata.ScopeMetrics{{Scope: instrumentation.Scope{Name: scopeName}, Metrics: metrics}}, err
+}
+
+// histogramDataPoint converts the cumulative buckets of a prometheus histogram to the bucket counts of
+// OTLP, the +Inf bucket being implied.
+func (p *gathererProducer) histogramDataPoint(metric *dto.Metric, now time.Time) metricdata.HistogramDataPoint[float64] {
+	histogram := metric.GetHistogram()
+	dataPoint := metricdata.HistogramDataPoint[float64]{
+		Attributes: attributes(metric),
+		StartTime:  p.start,
+		Time:       now,
+		Count:      histogram.GetSampleCount(),
+		Sum:        histogram.GetSampleSum(),
+	}
+
+	var previous uint64
+	for _, bucket := range histogram.GetBucket() {
+		if math.IsInf(bucket.GetUpperBound(), 1) {
+			break
+		}
+		dataPoint.Bounds = append(dataPoint.Bounds, bucket.GetUpperBound())
+		dataPoint.BucketCounts = append(dataPoint.BucketCounts, bucket.GetCumulativeCount()-previous)
+		previous = bucket.GetCumulativeCount()
+	}
+	dataPoint.BucketCounts = append(dataPoint.BucketCounts, histogram.GetSampleCount()-previous)
+
+	return dataPoint
+}
+
+// attributes returns the labels of a metric as attributes.
+func attributes(metric *dto.Metric) attribute.Set {
+	kvs := make([]attribute.KeyValue, 0, len(metric.GetLabel()))
+	for _, pair := range metric.GetLabel() {
+		kvs = append(kvs, attribute.String(pair.GetName(), pair.GetValue()))
+	}
+
+	return attribute.NewSet(kvs...)
+}